@@ -0,0 +1,129 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package app
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/jswidler/lockgit/pkg/util"
+)
+
+// Why a secret needs attention
+type DueReason string
+
+const (
+	DueExpired         DueReason = "expired"
+	DueExpiring        DueReason = "expires soon"
+	DueRotationOverdue DueReason = "rotation overdue"
+	DueRotation        DueReason = "rotation due soon"
+)
+
+// Secrets are reported by status when they expire or are due to be rotated within this long
+const DefaultDueWithin = 30 * 24 * time.Hour
+
+// A secret which has expired or should be rotated soon
+type DueRecord struct {
+	Path   string    `json:"path" yaml:"path"`
+	Owner  string    `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Reason DueReason `json:"reason" yaml:"reason"`
+	Date   time.Time `json:"date" yaml:"date"` // when the secret expires or should be rotated
+}
+
+// Returned by Due when some secrets need attention
+type DueError struct {
+	Records []DueRecord
+}
+
+func (err *DueError) Error() string {
+	if len(err.Records) == 1 {
+		return "1 secret has expired or is due to be rotated"
+	}
+	return fmt.Sprintf("%d secrets have expired or are due to be rotated", len(err.Records))
+}
+
+func (err *DueError) ExitCode() int {
+	return log.ExitDirty
+}
+
+// Returns the secrets which have expired or should be rotated, or will within the given duration, sorted by
+// date.  A DueError is returned if there are any.
+func Due(opts Options, within time.Duration) ([]DueRecord, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	files, errs := filterTags(ctx, manifest.Files, opts.Tags)
+	now := time.Now()
+	records := make([]DueRecord, 0)
+	for _, filemeta := range files {
+		meta, err := readMeta(ctx, filemeta)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if reason, date := dueCheck(meta, now, within); reason != "" {
+			records = append(records, DueRecord{Path: filemeta.RelPath, Owner: meta.Owner, Reason: reason, Date: date})
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Date.Before(records[j].Date)
+	})
+	if len(errs) > 0 {
+		return records, &PartialError{Errors: errs}
+	} else if len(records) > 0 {
+		return records, &DueError{Records: records}
+	}
+	return records, nil
+}
+
+// Returns why a secret needs attention and when, or "" if it does not need any within the duration.  When the
+// secret expires and should be rotated, the earlier date wins.
+func dueCheck(meta content.Metadata, now time.Time, within time.Duration) (DueReason, time.Time) {
+	var reason DueReason
+	var date time.Time
+	if meta.Expires != nil {
+		reason, date = DueExpiring, *meta.Expires
+		if !date.After(now) {
+			reason = DueExpired
+		}
+	}
+
+	if every, err := util.ParseDuration(meta.RotateEvery); meta.RotateEvery != "" && err == nil {
+		last := meta.Updated
+		if last.IsZero() {
+			last = meta.Created
+		}
+		if rotate := last.Add(every); !last.IsZero() && (reason == "" || rotate.Before(date)) {
+			reason, date = DueRotation, rotate
+			if !date.After(now) {
+				reason = DueRotationOverdue
+			}
+		}
+	}
+
+	if reason == "" || date.After(now.Add(within)) {
+		return "", time.Time{}
+	}
+	return reason, date
+}
@@ -24,328 +24,815 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/bmatcuk/doublestar"
 	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/git"
 	"github.com/jswidler/lockgit/pkg/gitignore"
+	"github.com/jswidler/lockgit/pkg/keystore"
 	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/jswidler/lockgit/pkg/util"
 	"github.com/pkg/errors"
-	"github.com/spf13/viper"
 )
 
 type Options struct {
 	NoUpdateGitignore bool
 	Force             bool
 	Wd                string
+	Rev               string   // read the vault from a git revision instead of the working directory
+	Exclude           []string // skip files matching these patterns
+	DryRun            bool     // report what would change without changing any files
+	Tags              []string // only use secrets which have all of these tags
+	RotateEvery       string   // how often secrets added to the vault should be rotated, such as 90d
+	Profile           string   // use one of the vault's profiles instead of the default profile
+	Jobs              int      // how many files to encrypt or decrypt at once, GOMAXPROCS if 0
+
+	KeyStore keystore.KeyStore // where the keys are saved, the one chosen by ~/.lockgit.yml if nil
+}
+
+// Returns the key store to use
+func (opts Options) keyStore() (keystore.KeyStore, error) {
+	return resolveKeyStore(opts.KeyStore)
+}
+
+func resolveKeyStore(store keystore.KeyStore) (keystore.KeyStore, error) {
+	if store != nil {
+		return store, nil
+	}
+	return keystore.FromConfig("")
 }
 
 // Initialize a lockgit vault in the working directory.  Returns an error if there is already
 // a lockgit vault in the directory.
-func InitVault(opts Options) error {
+func InitVault(opts Options) (KeyRecord, error) {
 	lockgitPath := filepath.Join(opts.Wd, ".lockgit")
 	exist, err := util.Exists(lockgitPath)
 	if exist {
-		return fmt.Errorf("Cannot initialize lockgit vault at %s: directory already exists", lockgitPath)
+		return KeyRecord{}, fmt.Errorf("Cannot initialize lockgit vault at %s: directory already exists", lockgitPath)
 	} else if err != nil {
-		log.FatalPanic(errors.Wrapf(err, "Cannot initialize lockgit vault at %s", lockgitPath))
+		return KeyRecord{}, errors.Wrapf(err, "Cannot initialize lockgit vault at %s", lockgitPath)
+	}
+	store, err := opts.keyStore()
+	if err != nil {
+		return KeyRecord{}, err
 	}
 	err = os.Mkdir(lockgitPath, 0755)
-	log.FatalPanic(errors.Wrap(err, "failed to make .lockgit directory"))
+	if err != nil {
+		return KeyRecord{}, errors.Wrap(err, "failed to make .lockgit directory")
+	}
 
 	config := content.NewLgConfig()
-	config.Write(filepath.Join(lockgitPath, "lgconfig"))
+	err = config.Write(filepath.Join(lockgitPath, "lgconfig"))
+	if err != nil {
+		return KeyRecord{}, err
+	}
 
-	vaultSettings := make(map[string]string)
-	vaultSettings["key"] = keyToString(genKey())
-	vaultSettings["path"] = opts.Wd
-	viper.Set("vaults."+config.Id, vaultSettings)
-	err = viper.WriteConfig()
-	log.FatalPanic(err)
+	err = store.Set(keystore.Entry{Id: config.Id, Key: keyToString(genKey()), Path: opts.Wd})
+	if err != nil {
+		return KeyRecord{}, err
+	}
 
-	log.Infof("Initialized empty lockgit vault in %s\nKey added to %s", lockgitPath, viper.ConfigFileUsed())
-	return nil
+	log.Infof("Initialized empty lockgit vault in %s\nKey added to %s", lockgitPath, store)
+	return KeyRecord{Id: config.Id, Path: opts.Wd, KeyStore: store.String()}, nil
 }
 
-func SetKey(opts Options, keystr string) error {
-	ctx, _ := loadcm(opts.Wd, loadcmopts{ctxOnly: true})
+func SetKey(opts Options, keystr string) (KeyRecord, error) {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return KeyRecord{}, err
+	}
 
 	if !opts.Force && ctx.Key != nil {
-		return fmt.Errorf("key already exists, use --force to overwrite")
+		return KeyRecord{}, fmt.Errorf("key already exists, use --force to overwrite")
 	}
 
-	_, err := keyToBytes(keystr)
-	log.FatalExit(err)
+	_, err = keyToBytes(keystr)
+	if err != nil {
+		return KeyRecord{}, err
+	}
 
-	viper.Set("vaults."+ctx.Config.Id+".key", keystr)
-	err = viper.WriteConfig()
-	log.FatalExit(err)
+	err = saveKey(ctx, keystr)
+	if err != nil {
+		return KeyRecord{}, err
+	}
 
 	log.Info("key saved")
-	return nil
+	return keyRecord(ctx), nil
 }
 
-func UnsetKey(opts Options) error {
+func UnsetKey(opts Options) (KeyRecord, error) {
 	if !opts.Force {
-		return fmt.Errorf("this operation will irrevocably delete the key for this vault and requires --force to proceed")
+		return KeyRecord{}, fmt.Errorf("this operation will irrevocably delete the key for this vault and requires --force to proceed")
 	}
 
-	ctx, _ := loadcm(opts.Wd, loadcmopts{ctxOnly: true})
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return KeyRecord{}, err
+	}
 
 	if ctx.Key == nil {
-		return fmt.Errorf("key is already unset")
+		return KeyRecord{}, fmt.Errorf("key is already unset")
 	}
 
-	viper.Set("vaults."+ctx.Config.Id+".key", "")
-	err := viper.WriteConfig()
-	log.FatalExit(err)
+	err = saveKey(ctx, "")
+	if err != nil {
+		return KeyRecord{}, err
+	}
 
 	log.Info("key deleted")
-	return nil
+	return keyRecord(ctx), nil
 }
 
-func GetKey(opts Options) string {
-	ctx, _ := loadcm(opts.Wd, loadcmopts{ctxOnly: true, keyRequired: true})
-	return keyToString(ctx.Key)
+func GetKey(opts Options) (string, error) {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return "", err
+	}
+	return keyToString(ctx.Key), nil
 }
 
-func Ls(opts Options) []string {
-	_, manifest := loadcm(opts.Wd, loadcmopts{})
+// Returns the paths of the secrets in the vault.  With tags, the key is required to read the metadata
+// and only the secrets with all of the tags are returned.
+func Ls(opts Options) ([]string, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: len(opts.Tags) > 0, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	files, errs := filterTags(ctx, manifest.Files, opts.Tags)
+	for _, err := range errs {
+		log.LogError(err)
+	}
 	out := make([]string, 0, 32)
-	for _, filemeta := range manifest.Files {
+	for _, filemeta := range files {
 		out = append(out, filemeta.RelPath)
 	}
-	return out
+	return out, nil
 }
 
-func LsGlobs(opts Options) []string {
-	ctx, _ := loadcm(opts.Wd, loadcmopts{ctxOnly: true})
+func LsGlobs(opts Options) ([]string, error) {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
 	out := make([]string, 0, 32)
 	for _, pattern := range ctx.Config.Patterns {
 		out = append(out, pattern)
 	}
-	return out
+	return out, nil
 }
 
-func AddToVault(opts Options, patterns []string) error {
-	ctx, manifest := loadcm(opts.Wd, loadcmopts{keyRequired: true})
+func AddToVault(opts Options, patterns []string) (changes []FileChange, err error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	inputs := append([]string(nil), patterns...)
+
+	if opts.RotateEvery != "" {
+		if _, err := util.ParseDuration(opts.RotateEvery); err != nil {
+			return nil, err
+		}
+	}
 
 	// map inputs to absolute paths
 	pathsToAbs(ctx.WorkingPath, &patterns)
 
 	// make sure all the paths are inside the vault before we add them
-	err := ensureSameContext(ctx, patterns)
+	err = ensureSameContext(ctx, patterns)
 	if err != nil {
-		return errors.Wrap(err, "failed to add")
+		return nil, errors.Wrap(err, "failed to add")
 	}
 
+	changes = make([]FileChange, 0, 16)
+
 	configChange, manifestChange := false, false
-	defer func() { saveChanges(ctx, manifest, manifestChange, configChange) }()
+	defer func() { saveChanges(ctx, opts, manifest, manifestChange, configChange, &err) }()
 
-	hadError := false
-	for _, pattern := range patterns {
+	excludes := excludePatterns(ctx, opts.Exclude)
+
+	var errs []error
+	for i, pattern := range patterns {
 		// expand each input to one or more filesΩ
-		rtype, files, pattern, err := util.GetFiles(pattern)
+		rtype, files, relGlob, err := expandInput(ctx, inputs[i], pattern)
 		if err != nil {
-			log.LogError(errors.Wrapf(err, "an error occurred processing the pattern %s", pattern))
-			hadError = true
+			errs = append(errs, errors.Wrapf(err, "an error occurred processing the pattern %s", relGlob))
 			continue
 		}
 		if len(files) == 0 {
-			log.LogError(errors.Errorf("cannot add %s: no files match", ctx.ProjRelPath(pattern)))
-			hadError = true
+			errs = append(errs, errors.Errorf("cannot add %s: no files match", relGlob))
 			continue
 		}
 
-		relGlob := ctx.ProjRelPath(pattern)
-		if !opts.NoUpdateGitignore {
-			gitignore.Add(ctx.ProjectPath, relGlob)
+		if !opts.NoUpdateGitignore && !opts.DryRun {
+			if err := gitignore.Add(ctx.ProjectPath, relGlob); err != nil {
+				errs = append(errs, err)
+			}
 		}
 
 		if rtype == util.Glob {
 			if added := ctx.Config.AddPattern(relGlob); added {
-				defer log.Info(fmt.Sprintf("added glob pattern '%s' to vault", relGlob))
+				defer logChange(opts, "added glob pattern '%s' to vault", "would add glob pattern '%s' to vault", relGlob)
+				changes = append(changes, FileChange{Path: relGlob, Action: ActionPatternAdded})
 				configChange = true
 			}
 		}
 
 		for _, filename := range files {
+			if rtype == util.Glob && firstMatchedPattern(ctx.Config, ctx.ProjRelPath(filename), excludes) != "" {
+				continue
+			}
 			err := addFile(ctx, &manifest, filename, opts)
 			if err != nil {
-				log.LogError(err)
-				hadError = true
+				errs = append(errs, err)
 			} else {
 				manifestChange = true
-				log.Info(fmt.Sprintf("added file '%s' to vault", ctx.RelPath(filename)))
+				changes = append(changes, FileChange{Path: ctx.ProjRelPath(filename), Action: ActionAdded})
+				logChange(opts, "added file '%s' to vault", "would add file '%s' to vault", ctx.RelPath(filename))
 			}
 		}
 	}
 
-	if hadError {
-		return errors.New("not all files were added successfully - set output for details")
+	// negated patterns go after the patterns they exclude files from
+	for _, exclude := range excludes {
+		negated := "!" + exclude
+		if !opts.NoUpdateGitignore && !opts.DryRun {
+			if err := gitignore.Add(ctx.ProjectPath, negated); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if added := ctx.Config.AddPattern(negated); added {
+			defer logChange(opts, "added exclude pattern '%s' to vault", "would add exclude pattern '%s' to vault", negated)
+			changes = append(changes, FileChange{Path: negated, Action: ActionPatternAdded})
+			configChange = true
+		}
 	}
 
-	return nil
+	if len(errs) > 0 {
+		return changes, &PartialError{Errors: errs}
+	}
+
+	return changes, nil
 }
 
-func RemoveFromVault(opts Options, patterns []string) {
-	ctx, manifest := loadcm(opts.Wd, loadcmopts{keyRequired: true})
+func RemoveFromVault(opts Options, patterns []string) (changes []FileChange, err error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
 
+	changes = make([]FileChange, 0, 16)
 	configChange, manifestChange := false, false
-	defer func() { saveChanges(ctx, manifest, manifestChange, configChange) }()
+	defer func() { saveChanges(ctx, opts, manifest, manifestChange, configChange, &err) }()
 
 	// See if any input is an exact glob match.  Remove it from the config if so
-	for _, input := range patterns {
-		if removed := ctx.Config.RemovePattern(input); removed {
-			defer log.Infof("removed glob pattern '%s' from vault", input)
+	saved := make([]string, len(patterns))
+	for i, input := range patterns {
+		saved[i] = input
+		if ctx.Config.GitignoreSyntax {
+			saved[i] = savedPattern(ctx, input)
+		}
+		if removed := ctx.Config.RemovePattern(saved[i]); removed {
+			defer logChange(opts, "removed glob pattern '%s' from vault", "would remove glob pattern '%s' from vault", saved[i])
+			changes = append(changes, FileChange{Path: saved[i], Action: ActionPatternRemoved})
 			configChange = true
+			patternConfig := ctx.Config
+			patternConfig.Patterns = []string{saved[i]}
+			if present, _ := patternConfig.PatternFiles(ctx.ProjectPath); len(present) > 0 {
+				keepInGitignore(opts, saved[i], "files matching it are still present")
+			} else {
+				removeFromGitignore(ctx, opts, saved[i])
+			}
 		}
 	}
 
 	// map inputs to absolute paths
 	pathsToAbs(ctx.WorkingPath, &patterns)
 
-	for _, pattern := range patterns {
+	for j, pattern := range patterns {
 		// Cannot iterate over the files since we are going to remove from it
 		for i, cur, l := 0, 0, len(manifest.Files); i < l; i++ {
 			file := manifest.Files[cur]
 			if pattern == file.AbsPath {
-				deleteFileHelper(ctx, &manifest, file)
+				changes = deleteFileHelper(ctx, opts, &manifest, file, changes)
 				manifestChange = true
-			} else if match, _ := doublestar.Match(pattern, file.AbsPath); match {
-				deleteFileHelper(ctx, &manifest, file)
+			} else if matchesInput(ctx, saved[j], pattern, file) {
+				changes = deleteFileHelper(ctx, opts, &manifest, file, changes)
 				manifestChange = true
 			} else {
 				cur++
 			}
 		}
 	}
+	return changes, nil
+}
+
+// Move a secret, or the secrets in a directory, to a new path in the vault.  The plaintext files are moved
+// too, if they are present, and the lines in the lockgit block of .gitignore follow them.  If the destination
+// is a directory which exists, the source is moved inside of it.
+func Move(opts Options, source, dest string) (changes []FileChange, err error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{source, dest}
+	pathsToAbs(ctx.WorkingPath, &paths)
+	if err := ensureSameContext(ctx, paths); err != nil {
+		return nil, errors.Wrap(err, "failed to move")
+	}
+	if err := checkAddable(ctx, paths[1]); err != nil {
+		return nil, err
+	}
+	srcRel, destRel := ctx.ProjRelPath(paths[0]), ctx.ProjRelPath(paths[1])
+	if isDir, _ := util.ExistsDir(paths[1]); isDir {
+		destRel = filepath.Join(destRel, filepath.Base(srcRel))
+	}
+
+	// map each secret to its new path
+	moves := make(map[string]string)
+	for _, filemeta := range manifest.Files {
+		if filemeta.RelPath == srcRel {
+			moves[filemeta.RelPath] = destRel
+		} else if strings.HasPrefix(filemeta.RelPath, srcRel+string(filepath.Separator)) {
+			moves[filemeta.RelPath] = filepath.Join(destRel, strings.TrimPrefix(filemeta.RelPath, srcRel))
+		}
+	}
+	if len(moves) == 0 {
+		return nil, fmt.Errorf("%s is not in the vault", ctx.RelPath(paths[0]))
+	}
+	for from, to := range moves {
+		if manifest.Find(to) >= 0 {
+			return nil, fmt.Errorf("cannot move %s: %s is already in the vault", from, to)
+		}
+		if present, _ := util.Exists(filepath.Join(ctx.ProjectPath, to)); present && !opts.Force {
+			return nil, fmt.Errorf("cannot move %s: %s already exists.  To overwrite it enable --force", from, to)
+		}
+	}
+
+	index := readIndex(ctx, opts)
+	defer saveIndex(index, opts)
+	changes = make([]FileChange, 0, len(moves))
+	manifestChange := false
+	defer func() { saveChanges(ctx, opts, manifest, manifestChange, false, &err) }()
+
+	var errs []error
+	for _, filemeta := range append([]content.Filemeta(nil), manifest.Files...) {
+		to, ok := moves[filemeta.RelPath]
+		if !ok {
+			continue
+		}
+		if err := moveSecret(ctx, index, &manifest, filemeta, to, opts); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to move %s", filemeta.RelPath))
+			continue
+		}
+		manifestChange = true
+		changes = append(changes, FileChange{Path: to, From: filemeta.RelPath, Action: ActionMoved})
+		logChange(opts, "moved '%s' to '%s' in vault", "would move '%s' to '%s' in vault",
+			ctx.RelPath(filemeta.AbsPath), ctx.RelPath(filepath.Join(ctx.ProjectPath, to)))
+	}
+	if len(errs) > 0 {
+		return changes, &PartialError{Errors: errs}
+	}
+	return changes, nil
 }
 
-func deleteFileHelper(ctx content.Context, manifest *content.Manifest, file content.Filemeta) {
-	err := deleteFileFromVault(ctx, manifest, file.AbsPath)
+// Move one secret to a new path relative to the project.  A new datafile is written for the new path, since
+// the path is part of the encrypted contents, and the metadata of the secret is kept.
+func moveSecret(ctx content.Context, index *content.Index, manifest *content.Manifest, filemeta content.Filemeta, to string, opts Options) error {
+	vaulted, err := content.ReadDatafile(ctx, filemeta)
+	if err != nil {
+		return err
+	}
+	data, err := vaulted.DecodeData()
+	if err != nil {
+		return err
+	}
+	moved, err := writeDatafile(ctx, index, content.NewDatafileFromData(ctx, to, data, os.FileMode(vaulted.Perm())), &filemeta, opts)
+	if err != nil {
+		return err
+	}
+	if err := deleteFileFromVault(ctx, manifest, filemeta.AbsPath, opts); err != nil {
+		return err
+	}
+	manifest.Add(moved)
+	if opts.DryRun {
+		return nil
+	}
+
+	if present, _ := util.Exists(filemeta.AbsPath); present {
+		_ = os.MkdirAll(filepath.Dir(moved.AbsPath), 0755)
+		if err := os.Rename(filemeta.AbsPath, moved.AbsPath); err != nil {
+			return err
+		}
+	}
+	// keep the plaintext ignored by git at its new path, unless a pattern of the vault already covers it
+	if !opts.NoUpdateGitignore && firstMatchedPattern(ctx.Config, to, ctx.Config.Patterns) == "" {
+		if err := gitignore.Add(ctx.ProjectPath, to); err != nil {
+			log.LogError(err)
+		}
+	}
+	removeFromGitignore(ctx, opts, filemeta.RelPath)
+	return nil
+}
+
+// True if a file in the manifest matches a glob pattern given to rm
+func matchesInput(ctx content.Context, saved, absPattern string, file content.Filemeta) bool {
+	if ctx.Config.GitignoreSyntax {
+		return gitignore.MatchPatterns(file.RelPath, false, []string{saved}) != ""
+	}
+	match, _ := doublestar.Match(absPattern, file.AbsPath)
+	return match
+}
+
+func deleteFileHelper(ctx content.Context, opts Options, manifest *content.Manifest, file content.Filemeta, changes []FileChange) []FileChange {
+	err := deleteFileFromVault(ctx, manifest, file.AbsPath, opts)
 	if err != nil {
 		log.LogError(err)
+		return changes
+	}
+	logChange(opts, "removed file '%s' from vault", "would remove file '%s' from vault", ctx.RelPath(file.AbsPath))
+	if present, _ := util.Exists(file.AbsPath); present {
+		keepInGitignore(opts, file.RelPath, "the file is still present")
 	} else {
-		log.Info(fmt.Sprintf("removed file '%s' from vault", ctx.RelPath(file.AbsPath)))
+		removeFromGitignore(ctx, opts, file.RelPath)
+	}
+	return append(changes, FileChange{Path: file.RelPath, Action: ActionRemoved})
+}
+
+// Warn that the line for a file or pattern was left in .gitignore, so a plaintext secret is not committed to git
+// after it is removed from the vault
+func keepInGitignore(opts Options, line, reason string) {
+	if opts.NoUpdateGitignore {
+		return
 	}
+	if opts.DryRun {
+		log.Warnf("would leave '%s' in .gitignore because %s", line, reason)
+		return
+	}
+	log.Warnf("'%s' was left in .gitignore because %s - delete it before removing the line", line, reason)
 }
 
-func Commit(opts Options) error {
-	ctx, manifest := loadcm(opts.Wd, loadcmopts{keyRequired: true})
+// Remove the line added to .gitignore for a file or pattern when it was added to the vault
+func removeFromGitignore(ctx content.Context, opts Options, line string) {
+	if opts.NoUpdateGitignore || opts.DryRun {
+		return
+	}
+	if err := gitignore.Remove(ctx.ProjectPath, line); err != nil {
+		log.LogError(err)
+	}
+}
+
+func Commit(opts Options) (changes []FileChange, err error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
 	opts.Force = true // for addFile
 
 	// Collect all the files which are tracked by patterns
-	patternMatched := make([]string, 0, 64)
-	if len(ctx.Config.Patterns) > 0 {
-		for _, pattern := range ctx.Config.Patterns {
-			absPattern := filepath.Join(ctx.ProjectPath, pattern)
-
-			_, files, _, err := util.GetFiles(absPattern)
-			log.FatalPanic(err)
-			patternMatched = append(patternMatched, files...)
-		}
+	patternMatched, err := ctx.Config.PatternFiles(ctx.ProjectPath)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(manifest.Files) == 0 && len(patternMatched) == 0 {
 		log.Info("vault is empty")
-		return nil
+		return []FileChange{}, nil
 	}
 
-	hadError := false
+	changes = make([]FileChange, 0, 16)
+	var errs []error
 	manifestChange := false
-	defer func() { saveChanges(ctx, manifest, manifestChange, false) }()
+	defer func() { saveChanges(ctx, opts, manifest, manifestChange, false, &err) }()
 
+	// the files in the manifest come first, followed by any files matched that were not seen in the manifest
+	inManifest := make(map[string]bool, len(manifest.Files))
 	for _, filemeta := range manifest.Files {
-		patternMatched = util.Filter(patternMatched, func(path string) bool {
-			return path != filemeta.AbsPath
-		})
+		inManifest[filemeta.AbsPath] = true
+	}
+	patternMatched = util.Filter(patternMatched, func(path string) bool {
+		return !inManifest[path]
+	})
+
+	// compare and encrypt the files in parallel, then update the manifest in order
+	type commitResult struct {
+		filemeta *content.Filemeta // the new entry for the manifest, if the file was encrypted
+		err      error
+	}
+	results := make([]commitResult, len(manifest.Files)+len(patternMatched))
+	index := readIndex(ctx, opts)
+	defer saveIndex(index, opts)
+	forEachIndex(opts.jobs(), len(results), func(i int) {
+		var filemeta content.Filemeta
+		var err error
+		if i < len(manifest.Files) {
+			filemeta, err = commitFile(ctx, index, manifest.Files[i], opts)
+		} else {
+			filemeta, err = commitNewFile(ctx, index, patternMatched[i-len(manifest.Files)], opts)
+		}
+		if err != nil {
+			results[i].err = err
+		} else if filemeta.Id != nil {
+			results[i].filemeta = &filemeta
+		}
+	})
 
-		datafile, err := content.NewDatafile(ctx, filemeta.AbsPath)
-		if err != nil && !os.IsNotExist(err) {
-			log.LogError(err)
-			hadError = true
-			continue
+	updated, added := results[:len(manifest.Files)], results[len(manifest.Files):]
+	for i, result := range updated {
+		if result.err != nil {
+			errs = append(errs, result.err)
+		} else if result.filemeta != nil {
+			manifestChange = true
+			updateManifest(ctx, &manifest, i, *result.filemeta, opts)
+			changes = append(changes, FileChange{Path: result.filemeta.RelPath, Action: ActionUpdated})
+			logChange(opts, "'%s' updated", "would update '%s'", ctx.RelPath(result.filemeta.AbsPath))
 		}
+	}
+	for _, result := range added {
+		if result.err != nil {
+			errs = append(errs, result.err)
+		} else if result.filemeta != nil {
+			manifestChange = true
+			updateManifest(ctx, &manifest, -1, *result.filemeta, opts)
+			changes = append(changes, FileChange{Path: result.filemeta.RelPath, Action: ActionAdded})
+			logChange(opts, "'%s' added to the vault", "would add '%s' to the vault", ctx.RelPath(result.filemeta.AbsPath))
+		}
+	}
 
-		fileMatches, err := datafile.MatchesCurrent(filemeta)
-		if err != nil {
-			log.LogError(err)
-			hadError = true
-		} else if !fileMatches {
-			err := addFile(ctx, &manifest, filemeta.AbsPath, opts)
-			if err != nil {
-				log.LogError(err)
-				hadError = true
-			} else {
-				manifestChange = true
-				log.Info(fmt.Sprintf("'%s' updated", ctx.RelPath(filemeta.AbsPath)))
+	if len(errs) > 0 {
+		return changes, &PartialError{Errors: errs}
+	}
+
+	return changes, nil
+}
+
+// Decrypt the secrets in the vault.  If paths are provided, only secrets matching one of the paths or
+// glob patterns are opened.  Secrets matching one of the exclude patterns are skipped.  If some secrets
+// could not be opened, a PartialError is returned along with the changes which were made.
+func OpenVault(opts Options, paths []string) ([]FileChange, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, rev: opts.Rev, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil || isEmpty(manifest) {
+		return []FileChange{}, err
+	}
+	changes := make([]FileChange, 0, len(manifest.Files))
+	selected, errs := selectFiles(ctx, manifest, paths, opts.Exclude, opts.Tags)
+	opened := make([]FileChange, len(selected))
+	openErrs := make([]error, len(selected))
+	index := readIndex(ctx, opts)
+	forEachIndex(opts.jobs(), len(selected), func(i int) {
+		opened[i], openErrs[i] = openFromVault(ctx, index, selected[i], opts)
+	})
+	saveIndex(index, opts)
+	for i, filemeta := range selected {
+		if openErrs[i] != nil {
+			errs = append(errs, errors.Wrapf(openErrs[i], "error opening '%s'", filemeta.RelPath))
+		} else {
+			logOpened(ctx, opened[i], opts)
+			changes = append(changes, opened[i])
+		}
+	}
+	if len(errs) > 0 {
+		return changes, &PartialError{Errors: errs}
+	}
+	return changes, nil
+}
+
+// Returns the decrypted contents of a single secret
+func Cat(opts Options, path string) ([]byte, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, rev: opts.Rev, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{path}
+	pathsToAbs(ctx.WorkingPath, &paths)
+	relPath := ctx.ProjRelPath(paths[0])
+
+	mindx := manifest.Find(relPath)
+	if mindx < 0 {
+		if opts.Rev != "" {
+			return nil, fmt.Errorf("%s is not in the vault at %s", ctx.RelPath(paths[0]), opts.Rev)
+		}
+		return nil, fmt.Errorf("%s is not in the vault", ctx.RelPath(paths[0]))
+	}
+
+	datafile, err := content.ReadDatafile(ctx, manifest.Files[mindx])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", relPath)
+	}
+	return datafile.DecodeData()
+}
+
+// Delete the plaintext secrets.  If paths are provided, only secrets matching one of the paths or
+// glob patterns are deleted.  Secrets matching one of the exclude patterns are skipped.  Without paths, files
+// registered by render are deleted too.  If some secrets could not be deleted, a PartialError is returned
+// along with the changes which were made.
+func CloseVault(opts Options, paths []string) ([]FileChange, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil || isEmpty(manifest) {
+		return []FileChange{}, err
+	}
+	changes := make([]FileChange, 0, len(manifest.Files))
+	selected, errs := selectFiles(ctx, manifest, paths, opts.Exclude, opts.Tags)
+	deleted := make([]bool, len(selected))
+	deleteErrs := make([]error, len(selected))
+	index := readIndex(ctx, opts)
+	forEachIndex(opts.jobs(), len(selected), func(i int) {
+		deleted[i], deleteErrs[i] = deletePlaintextFile(ctx, index, selected[i], opts)
+	})
+	saveIndex(index, opts)
+	for i, filemeta := range selected {
+		if deleteErrs[i] != nil {
+			errs = append(errs, deleteErrs[i])
+		} else if deleted[i] {
+			changes = append(changes, FileChange{Path: filemeta.RelPath, Action: ActionClosed})
+		}
+	}
+	if len(paths) == 0 {
+		rendered, renderErrs := deleteRenderedFiles(ctx, opts)
+		changes = append(changes, rendered...)
+		errs = append(errs, renderErrs...)
+	}
+	if len(errs) > 0 {
+		return changes, &PartialError{Errors: errs}
+	}
+	return changes, nil
+}
+
+// Returns the files in the manifest which match one of the paths, or every file if there are no paths,
+// which do not match any of the exclude patterns, and which have all of the tags.  The paths and patterns
+// are relative to the working directory.  An error is returned for each path which does not match any file.
+func selectFiles(ctx content.Context, manifest content.Manifest, paths, exclude, tags []string) ([]content.Filemeta, []error) {
+	paths = projRelPaths(ctx, paths)
+	exclude = projRelPaths(ctx, exclude)
+
+	matched := make(map[string]bool)
+	selected := make([]content.Filemeta, 0, len(manifest.Files))
+	for _, filemeta := range manifest.Files {
+		if len(paths) > 0 {
+			pattern := firstMatchingPath(filemeta.RelPath, paths)
+			if pattern == "" {
+				continue
 			}
+			matched[pattern] = true
+		}
+		if firstMatchingPath(filemeta.RelPath, exclude) != "" {
+			continue
 		}
+		selected = append(selected, filemeta)
 	}
 
-	// iterate through any files matched, but that were not seen in the manifest
-	for _, filename := range patternMatched {
-		err := addFile(ctx, &manifest, filename, opts)
-		if err != nil {
-			log.LogError(err)
-			hadError = true
+	var errs []error
+	for _, path := range paths {
+		if !matched[path] {
+			errs = append(errs, errors.Errorf("no secrets in the vault match %s", path))
+		}
+	}
+	selected, tagErrs := filterTags(ctx, selected, tags)
+	return selected, append(errs, tagErrs...)
+}
+
+// Map exclude paths to the patterns to save in the vault.  Directories exclude everything inside them.
+func excludePatterns(ctx content.Context, exclude []string) []string {
+	patterns := make([]string, len(exclude))
+	for i, input := range exclude {
+		relPath := projRelPaths(ctx, []string{input})[0]
+		if isDir, _ := util.ExistsDir(filepath.Join(ctx.ProjectPath, relPath)); isDir {
+			patterns[i] = filepath.Join(relPath, "**")
 		} else {
-			manifestChange = true
-			log.Info(fmt.Sprintf("'%s' added to the vault", ctx.RelPath(filename)))
+			patterns[i] = savedPattern(ctx, input)
 		}
 	}
+	return patterns
+}
 
-	if hadError {
-		return errors.New("not all files were committed successfully - set output for details")
+// Expand an input to add, relative to the working directory, to the files it matches.  Also returns the
+// pattern to save for it, relative to the project.  Directories are saved as a pattern matching everything
+// inside them.
+func expandInput(ctx content.Context, input, absInput string) (util.FileResult, []string, string, error) {
+	if !ctx.Config.GitignoreSyntax {
+		rtype, files, absPattern, err := util.GetFiles(absInput)
+		return rtype, files, ctx.ProjRelPath(absPattern), err
 	}
 
-	return nil
+	relPath := ctx.ProjRelPath(absInput)
+	stat, err := os.Lstat(absInput)
+	if err == nil && !stat.IsDir() {
+		return util.File, []string{absInput}, relPath, nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return util.File, nil, relPath, err
+	}
+
+	pattern := savedPattern(ctx, input)
+	if err == nil {
+		pattern = filepath.ToSlash(relPath) + "/**"
+	}
+	files, err := gitignore.Files(ctx.ProjectPath, []string{pattern})
+	return util.Glob, files, pattern, err
 }
 
-func OpenVault(opts Options) {
-	ctx, manifest := loadcm(opts.Wd, loadcmopts{keyRequired: true, notEmpty: true})
-	for _, filemeta := range manifest.Files {
-		if err := openFromVault(ctx, filemeta, opts); err != nil {
-			log.LogError(errors.Wrapf(err, "error opening '%s': %s", filemeta.RelPath, err))
+// Convert a glob pattern relative to the working directory to the pattern to save in the vault.  With the
+// .gitignore syntax, the saved pattern has the same meaning as the input would in a .gitignore file in the
+// working directory.
+func savedPattern(ctx content.Context, input string) string {
+	if !ctx.Config.GitignoreSyntax {
+		return projRelPaths(ctx, []string{input})[0]
+	}
+	if filepath.IsAbs(input) || strings.HasPrefix(input, "./") {
+		// anchor the pattern so it only matches the path it names
+		relPath := filepath.ToSlash(projRelPaths(ctx, []string{input})[0])
+		if !strings.Contains(relPath, "/") {
+			relPath = "/" + relPath
 		}
+		return relPath
 	}
+	return gitignore.Rebase(input, ctx.ProjRelPath(ctx.WorkingPath))
 }
 
-func CloseVault(opts Options) {
-	ctx, manifest := loadcm(opts.Wd, loadcmopts{keyRequired: true, notEmpty: true})
-	for _, filemeta := range manifest.Files {
-		if err := deletePlaintextFile(ctx, filemeta, opts); err != nil {
-			log.LogError(err)
+// Map paths relative to the working directory to clean paths relative to the project.  The project root is ".".
+func projRelPaths(ctx content.Context, paths []string) []string {
+	out := make([]string, len(paths))
+	copy(out, paths)
+	pathsToAbs(ctx.WorkingPath, &out)
+	for i, path := range out {
+		out[i] = filepath.Clean(ctx.ProjRelPath(path))
+	}
+	return out
+}
+
+// Returns true if path matches one of the patterns, or if there are no patterns
+func matchesAny(path string, patterns []string) bool {
+	return len(patterns) == 0 || firstMatchingPath(path, patterns) != ""
+}
+
+// Returns the first pattern which is the path, a directory containing the path, or a glob matching the path.
+// The pattern "." is the project root, which contains every path.
+func firstMatchingPath(path string, patterns []string) string {
+	for _, pattern := range patterns {
+		if pattern == "." || path == pattern || strings.HasPrefix(path, pattern+string(filepath.Separator)) {
+			return pattern
+		}
+		if match, _ := doublestar.Match(pattern, path); match {
+			return pattern
 		}
 	}
+	return ""
 }
 
 type loadcmopts struct {
 	ctxOnly     bool
 	keyRequired bool
-	notEmpty    bool
+	rev         string            // git revision to read the manifest and datafiles from
+	profile     string            // profile of the vault to load
+	keyStore    keystore.KeyStore // the key store from Options, which may be nil
 }
 
-func loadcm(wd string, opts loadcmopts) (content.Context, content.Manifest) {
-	ctx, err := content.FromPath(wd)
+func loadcm(wd string, opts loadcmopts) (content.Context, content.Manifest, error) {
+	store, err := resolveKeyStore(opts.keyStore)
+	if err != nil {
+		return content.Context{}, content.Manifest{}, err
+	}
+	ctx, err := content.FromProfile(wd, opts.profile, store)
 	if err != nil && (opts.keyRequired || !content.IsKeyLoadError(err)) {
-		log.FatalExit(err)
+		return ctx, content.Manifest{}, err
+	}
+	if opts.rev != "" {
+		if !git.IsRepo(ctx.ProjectPath) {
+			return ctx, content.Manifest{}, fmt.Errorf("cannot read revision %s: %s is not in a git repository", opts.rev, ctx.RelPath(ctx.ProjectPath))
+		}
+		commit, err := git.ResolveCommit(ctx.ProjectPath, opts.rev)
+		if err != nil {
+			return ctx, content.Manifest{}, err
+		}
+		ctx.Source = git.RevSource{Dir: ctx.ProjectPath, Rev: commit}
 	}
 	if opts.ctxOnly {
-		return ctx, content.Manifest{}
+		return ctx, content.Manifest{}, nil
 	}
 
 	_ = os.Mkdir(ctx.DataPath, 0755)
 
 	manifest, err := ctx.ImportManifest()
-	log.FatalExit(err)
+	return ctx, manifest, err
+}
 
-	if opts.notEmpty && len(manifest.Files) == 0 {
-		fmt.Println("vault is empty")
-		os.Exit(0)
+// Returns true if there are no secrets in the manifest, which is logged since there is nothing to do
+func isEmpty(manifest content.Manifest) bool {
+	if len(manifest.Files) == 0 {
+		log.Info("vault is empty")
+		return true
 	}
-	return ctx, manifest
+	return false
+}
+
+// Save the key of the active profile in the key store
+func saveKey(ctx content.Context, key string) error {
+	entry, err := ctx.KeyEntry()
+	if err != nil {
+		return err
+	}
+	entry.Key = key
+	return ctx.KeyStore.Set(entry)
 }
 
 func pathsToAbs(basepath string, files *[]string) {
@@ -358,22 +845,31 @@ func pathsToAbs(basepath string, files *[]string) {
 	}
 }
 
-func firstMatchedPattern(path string, patterns []string) string {
-	for _, pattern := range patterns {
-		match, err := doublestar.Match(pattern, path)
-		log.FatalPanic(err)
-		if match {
-			return pattern
-		}
+// Returns the first pattern which matches a path relative to the project, or "" if none match or the path
+// is excluded by a negated pattern.  The patterns use the syntax of the vault's config.  A pattern which is
+// not a valid glob does not match anything.
+func firstMatchedPattern(config content.LgConfig, path string, patterns []string) string {
+	pattern, err := config.MatchPatterns(path, patterns)
+	if err != nil {
+		return ""
 	}
-	return ""
+	return pattern
 }
 
-func saveChanges(ctx content.Context, manifest content.Manifest, manifestChanges, configChanges bool) {
+// Write the manifest and config if they were changed.  If they cannot be written, the error is stored in err
+// unless it already holds an error.
+func saveChanges(ctx content.Context, opts Options, manifest content.Manifest, manifestChanges, configChanges bool, err *error) {
+	if opts.DryRun {
+		return
+	}
+	var saveErr error
 	if manifestChanges {
-		manifest.Export()
+		saveErr = manifest.Export()
+	}
+	if configChanges && saveErr == nil {
+		saveErr = ctx.Config.Write(ctx.ConfigPath)
 	}
-	if configChanges {
-		ctx.Config.Write(ctx.ConfigPath)
+	if saveErr != nil && *err == nil {
+		*err = saveErr
 	}
 }
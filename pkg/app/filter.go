@@ -0,0 +1,169 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/git"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/jswidler/lockgit/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// Encrypt a file for git as a clean filter.  The plaintext is read from in and the encrypted form is
+// written to out.  The path is the file's path relative to the working directory, which is used to find
+// the vault.  Input which is already encrypted is passed through unchanged.
+func FilterClean(opts Options, path string, in io.Reader, out io.Writer) error {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if content.IsFiltered(data) {
+		_, err = out.Write(data)
+		return err
+	}
+
+	ctx, absPath, err := filterContext(opts, path)
+	if err != nil {
+		return err
+	}
+	if ctx.Key == nil {
+		return fmt.Errorf("cannot encrypt %s: the key for the vault is not available", path)
+	}
+
+	ciphertext, err := content.EncryptFiltered(ctx, ctx.ProjRelPath(absPath), data)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encrypt %s", path)
+	}
+	_, err = out.Write(ciphertext)
+	return err
+}
+
+// Decrypt a file from git as a smudge filter.  The encrypted form is read from in and the plaintext is
+// written to out.  If the input is not encrypted or the key is not available, the input is passed
+// through unchanged so that checkouts work without the key.
+func FilterSmudge(opts Options, path string, in io.Reader, out io.Writer) error {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if !content.IsFiltered(data) {
+		_, err = out.Write(data)
+		return err
+	}
+
+	ctx, _, err := filterContext(opts, path)
+	if err != nil {
+		return err
+	}
+	if ctx.Key == nil {
+		_, err = out.Write(data)
+		return err
+	}
+
+	datafile, err := content.DecryptFiltered(ctx, data)
+	if err == nil {
+		var plaintext []byte
+		plaintext, err = datafile.DecodeData()
+		if err == nil {
+			_, err = out.Write(plaintext)
+			return err
+		}
+	}
+	log.LogError(errors.Wrapf(err, "unable to decrypt %s, leaving it encrypted", path))
+	_, err = out.Write(data)
+	return err
+}
+
+func filterContext(opts Options, path string) (content.Context, string, error) {
+	paths := []string{path}
+	pathsToAbs(opts.Wd, &paths)
+	ctx, _, err := loadcm(filepath.Dir(paths[0]), loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	return ctx, paths[0], err
+}
+
+// Configure git to encrypt files matching the saved glob patterns with the clean and smudge filters.
+// Returns a warning for each file in the vault which is ignored by git and so cannot be committed.
+func InstallFilter(opts Options) ([]string, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	if !git.IsRepo(ctx.ProjectPath) {
+		return nil, fmt.Errorf("%s is not in a git repository", ctx.RelPath(ctx.ProjectPath))
+	}
+	if len(ctx.Config.Patterns) == 0 {
+		return nil, errors.New("there are no glob patterns saved in the vault")
+	}
+
+	config := map[string]string{
+		"filter.lockgit.clean":    "lockgit filter clean %f",
+		"filter.lockgit.smudge":   "lockgit filter smudge %f",
+		"filter.lockgit.required": "true",
+		"diff.lockgit.textconv":   "lockgit textconv",
+	}
+	for key, value := range config {
+		if err := git.SetConfig(ctx.ProjectPath, key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pattern := range ctx.Config.Patterns {
+		attrs := []string{"filter=lockgit", "diff=lockgit"}
+		if util.IsNegated(pattern) {
+			// .gitattributes does not support negated patterns, so unset the attributes instead
+			attrs = []string{"-filter", "-diff"}
+		}
+		if ctx.Config.GitignoreSyntax || util.IsNegated(pattern) {
+			// .gitattributes does not support directory patterns either
+			pattern = ctx.Config.PatternGlob(pattern)
+		}
+		for _, attr := range attrs {
+			changed, err := git.AddAttribute(ctx.ProjectPath, pattern, attr)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to update .gitattributes")
+			}
+			if changed {
+				log.Infof("added '%s %s' to .gitattributes", pattern, attr)
+			}
+		}
+	}
+
+	files := make([]string, 0, len(manifest.Files))
+	for _, filemeta := range manifest.Files {
+		files = append(files, filemeta.RelPath)
+	}
+	ignored, err := git.Ignored(ctx.ProjectPath, files)
+	if err != nil {
+		return nil, err
+	}
+	warnings := make([]string, 0, len(ignored))
+	for _, file := range ignored {
+		warnings = append(warnings, fmt.Sprintf("%s is ignored by git - remove it from .gitignore to commit it with the filter", file))
+	}
+	return warnings, nil
+}
@@ -0,0 +1,203 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/diff"
+	"github.com/jswidler/lockgit/pkg/git"
+	"github.com/pkg/errors"
+)
+
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Changed ChangeType = "changed"
+	Removed ChangeType = "removed"
+)
+
+// A git commit which changed one or more secrets in the vault
+type HistoryEntry struct {
+	Commit  string         `json:"commit" yaml:"commit"`
+	Author  string         `json:"author" yaml:"author"`
+	Email   string         `json:"email" yaml:"email"`
+	Date    time.Time      `json:"date" yaml:"date"`
+	Subject string         `json:"subject" yaml:"subject"`
+	Changes []SecretChange `json:"changes" yaml:"changes"`
+}
+
+// A change to a single secret in a commit
+type SecretChange struct {
+	Path  string     `json:"path" yaml:"path"`
+	Type  ChangeType `json:"type" yaml:"type"`
+	OldId string     `json:"oldId,omitempty" yaml:"oldId,omitempty"`
+	NewId string     `json:"newId,omitempty" yaml:"newId,omitempty"`
+	Diff  string     `json:"diff,omitempty" yaml:"diff,omitempty"` // only set when diffs are requested
+}
+
+// Walk the git history of the manifest and return the commits which added, changed or removed
+// secrets, newest first.  If paths are provided, only changes to secrets matching one of the
+// paths or glob patterns are returned.  If showDiff is set the key is required and each change
+// will include a diff of the decrypted contents.
+func History(opts Options, paths []string, showDiff bool) ([]HistoryEntry, error) {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, keyRequired: showDiff, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	if !git.IsRepo(ctx.ProjectPath) {
+		return nil, fmt.Errorf("%s is not in a git repository", ctx.RelPath(ctx.ProjectPath))
+	}
+
+	paths = projRelPaths(ctx, paths)
+
+	manifestPath := ctx.ProjRelPath(ctx.ManifestPath)
+	commits, err := git.Log(ctx.ProjectPath, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]HistoryEntry, 0, len(commits))
+	for _, commit := range commits {
+		current, err := manifestAtRev(ctx, commit.Hash)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read manifest in commit %s", commit.ShortHash())
+		}
+		previous := content.Manifest{}
+		if len(commit.Parents) > 0 {
+			previous, err = manifestAtRev(ctx, commit.Parents[0])
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read manifest in commit %s", commit.Parents[0])
+			}
+		}
+
+		changes := make([]SecretChange, 0, 4)
+		for _, change := range compareManifests(previous, current) {
+			if !matchesAny(change.Path, paths) {
+				continue
+			}
+			if showDiff {
+				prevRev := ""
+				if len(commit.Parents) > 0 {
+					prevRev = commit.Parents[0]
+				}
+				change.Diff = secretDiff(ctx, change, prevRev, commit.Hash)
+			}
+			changes = append(changes, change)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		history = append(history, HistoryEntry{
+			Commit:  commit.Hash,
+			Author:  commit.Author,
+			Email:   commit.Email,
+			Date:    commit.Date,
+			Subject: commit.Subject,
+			Changes: changes,
+		})
+	}
+	return history, nil
+}
+
+func manifestAtRev(ctx content.Context, rev string) (content.Manifest, error) {
+	manifestPath := ctx.ProjRelPath(ctx.ManifestPath)
+	if !git.Exists(ctx.ProjectPath, rev, manifestPath) {
+		return content.Manifest{}, nil
+	}
+	data, err := git.Show(ctx.ProjectPath, rev, manifestPath)
+	if err != nil {
+		return content.Manifest{}, err
+	}
+	return content.ParseManifest(ctx, data)
+}
+
+// Returns the changes between two manifests, sorted by path
+func compareManifests(previous, current content.Manifest) []SecretChange {
+	changes := make([]SecretChange, 0, 4)
+	i, j := 0, 0
+	for i < len(previous.Files) || j < len(current.Files) {
+		switch {
+		case j == len(current.Files) || (i < len(previous.Files) && previous.Files[i].RelPath < current.Files[j].RelPath):
+			old := previous.Files[i]
+			changes = append(changes, SecretChange{Path: old.RelPath, Type: Removed, OldId: old.IdString()})
+			i++
+		case i == len(previous.Files) || current.Files[j].RelPath < previous.Files[i].RelPath:
+			cur := current.Files[j]
+			changes = append(changes, SecretChange{Path: cur.RelPath, Type: Added, NewId: cur.IdString()})
+			j++
+		default:
+			old, cur := previous.Files[i], current.Files[j]
+			if old.IdString() != cur.IdString() {
+				changes = append(changes, SecretChange{Path: cur.RelPath, Type: Changed, OldId: old.IdString(), NewId: cur.IdString()})
+			}
+			i++
+			j++
+		}
+	}
+	return changes
+}
+
+func secretDiff(ctx content.Context, change SecretChange, prevRev, rev string) string {
+	var before, after []byte
+	if change.OldId != "" {
+		data, err := secretAtRev(ctx, prevRev, change.OldId)
+		if err != nil {
+			return fmt.Sprintf("unable to read previous version: %s\n", err)
+		}
+		before = data
+	}
+	if change.NewId != "" {
+		data, err := secretAtRev(ctx, rev, change.NewId)
+		if err != nil {
+			return fmt.Sprintf("unable to read new version: %s\n", err)
+		}
+		after = data
+	}
+	if diff.IsBinary(before) || diff.IsBinary(after) {
+		return fmt.Sprintf("Binary secret %s differs\n", change.Path)
+	}
+	oldName, newName := "a/"+change.Path, "b/"+change.Path
+	if change.Type == Added {
+		oldName = "/dev/null"
+	} else if change.Type == Removed {
+		newName = "/dev/null"
+	}
+	return diff.Unified(oldName, newName, before, after, 3)
+}
+
+func secretAtRev(ctx content.Context, rev, id string) ([]byte, error) {
+	ciphertext, err := git.Show(ctx.ProjectPath, rev, filepath.Join(".lockgit", "data", id))
+	if err != nil {
+		return nil, err
+	}
+	datafile, err := content.DecodeDatafile(ctx, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return datafile.DecodeData()
+}
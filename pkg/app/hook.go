@@ -0,0 +1,181 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jswidler/lockgit/pkg/git"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const hookHeader = "# Installed by lockgit."
+
+// Returns the command which runs the pre-commit check for a vault.  Git runs hooks from the top of the work
+// tree, so the command changes to the vault's directory first, relative to the top so the repository can be moved.
+func hookCommand(relPath string) string {
+	if relPath == "." {
+		return "lockgit hook pre-commit"
+	}
+	return fmt.Sprintf("cd '%s' && lockgit hook pre-commit", strings.ReplaceAll(filepath.ToSlash(relPath), "'", `'\''`))
+}
+
+func preCommitHook(relPath string) string {
+	return "#!/bin/sh\n" + hookHeader + "  Blocks commits of plaintext secrets and of secrets not committed to the vault.\n" +
+		hookCommand(relPath) + "\n"
+}
+
+// Returns the path of the project relative to the top of its git work tree
+func repoRelPath(projectPath string) (string, error) {
+	top, err := git.TopLevel(projectPath)
+	if err != nil {
+		return "", err
+	}
+	// git resolves symbolic links in the top level
+	if resolved, err := filepath.EvalSymlinks(projectPath); err == nil {
+		projectPath = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(top); err == nil {
+		top = resolved
+	}
+	return filepath.Rel(top, projectPath)
+}
+
+// Install a git pre-commit hook which runs lockgit hook pre-commit in the vault's directory.  An existing
+// hook is only replaced if it was installed by lockgit or force is set.
+func InstallHook(opts Options) error {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return err
+	}
+
+	if !git.IsRepo(ctx.ProjectPath) {
+		return fmt.Errorf("%s is not in a git repository", ctx.RelPath(ctx.ProjectPath))
+	}
+	hooksDir, err := git.HooksDir(ctx.ProjectPath)
+	if err != nil {
+		return err
+	}
+	hookPath := filepath.Join(hooksDir, "pre-commit")
+	relPath, err := repoRelPath(ctx.ProjectPath)
+	if err != nil {
+		return err
+	}
+	hook := preCommitHook(relPath)
+
+	existing, err := ioutil.ReadFile(hookPath)
+	if err == nil {
+		installed := strings.HasPrefix(string(existing), "#!/bin/sh\n"+hookHeader)
+		if string(existing) == hook || (!installed && strings.Contains(string(existing), "lockgit hook pre-commit")) {
+			log.Infof("pre-commit hook is already installed at %s", ctx.RelPath(hookPath))
+			return nil
+		} else if !installed && !opts.Force {
+			return fmt.Errorf("a pre-commit hook already exists at %s - add \"%s\" to it or use --force to replace it",
+				ctx.RelPath(hookPath), hookCommand(relPath))
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	_ = os.MkdirAll(hooksDir, 0755)
+	err = ioutil.WriteFile(hookPath, []byte(hook), 0755)
+	if err != nil {
+		return errors.Wrap(err, "unable to write pre-commit hook")
+	}
+	log.Infof("installed pre-commit hook at %s", ctx.RelPath(hookPath))
+	return nil
+}
+
+// Check the vault before a git commit.  Returns a description of each problem found: plaintext
+// secrets which are staged in git, and secrets which have changed but were not committed to the vault.
+// Every profile of the vault is checked, not only the one in the options.
+func PreCommitCheck(opts Options) ([]string, error) {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	if !git.IsRepo(ctx.ProjectPath) {
+		return nil, fmt.Errorf("%s is not in a git repository", ctx.RelPath(ctx.ProjectPath))
+	}
+
+	staged, err := git.StagedFiles(ctx.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
+	// files encrypted by the clean filter are safe to commit
+	filters, err := git.CheckAttr(ctx.ProjectPath, "filter", staged)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]string, 0)
+	for _, profile := range append([]string{""}, ctx.Config.Profiles...) {
+		profileOpts := opts
+		profileOpts.Profile = profile
+		profileProblems, err := preCommitCheckProfile(profileOpts, staged, filters)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, profileProblems...)
+	}
+	return problems, nil
+}
+
+// Check the secrets of one profile of the vault before a git commit
+func preCommitCheckProfile(opts Options, staged []string, filters map[string]string) ([]string, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]string, 0)
+	for _, file := range staged {
+		if strings.HasPrefix(file, ".lockgit"+string(filepath.Separator)) || filters[file] == "lockgit" {
+			continue
+		}
+		if manifest.Find(file) >= 0 {
+			problems = append(problems, fmt.Sprintf("%s is a secret in the vault and is staged in git", file))
+		} else if pattern := firstMatchedPattern(ctx.Config, file, ctx.Config.Patterns); pattern != "" {
+			problems = append(problems, fmt.Sprintf("%s matches the vault pattern '%s' and is staged in git", file, pattern))
+		}
+	}
+
+	records, err := Status(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		switch record.State {
+		case StateUpdated:
+			problems = append(problems, fmt.Sprintf("%s has changed but the change is not committed to the vault", record.Path))
+		case StateNew:
+			problems = append(problems, fmt.Sprintf("%s matches a vault pattern but is not committed to the vault", record.Path))
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,85 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jswidler/lockgit/pkg/git"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/jswidler/lockgit/pkg/util"
+)
+
+// Returned by CheckGitignore when git would not ignore some of the plaintext secrets
+type NotIgnoredError struct {
+	Paths []string
+}
+
+func (err *NotIgnoredError) Error() string {
+	if len(err.Paths) == 1 {
+		return "1 secret is not ignored by git"
+	}
+	return fmt.Sprintf("%d secrets are not ignored by git", len(err.Paths))
+}
+
+func (err *NotIgnoredError) ExitCode() int {
+	return log.ExitDirty
+}
+
+// Returns the files in the vault, or matching one of its patterns, which git would not ignore, and a
+// NotIgnoredError if there are any.  Git decides, so nested .gitignore files, .git/info/exclude and the
+// global excludes file are all taken into account.
+func CheckGitignore(opts Options) ([]string, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	if !git.IsRepo(ctx.ProjectPath) {
+		return nil, fmt.Errorf("%s is not in a git repository", ctx.RelPath(ctx.ProjectPath))
+	}
+
+	patternMatched, err := ctx.Config.PatternFiles(ctx.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(manifest.Files)+len(patternMatched))
+	for _, filemeta := range manifest.Files {
+		files = append(files, filemeta.RelPath)
+	}
+	files = util.Unique(append(files, projRelPaths(ctx, patternMatched)...))
+
+	ignored, err := git.Ignored(ctx.ProjectPath, files)
+	if err != nil {
+		return nil, err
+	}
+	isIgnored := make(map[string]bool)
+	for _, file := range ignored {
+		isIgnored[file] = true
+	}
+	notIgnored := util.Filter(files, func(file string) bool {
+		return !isIgnored[file]
+	})
+	sort.Strings(notIgnored)
+	if len(notIgnored) > 0 {
+		return notIgnored, &NotIgnoredError{Paths: notIgnored}
+	}
+	return notIgnored, nil
+}
@@ -0,0 +1,73 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+)
+
+// Read the local index, or return nil if it should not be used because the vault is read from a git revision
+func readIndex(ctx content.Context, opts Options) *content.Index {
+	if opts.Rev != "" {
+		return nil
+	}
+	return content.ReadIndex(ctx)
+}
+
+// Save the local index.  It is only a cache, so errors are not reported except when debugging.
+func saveIndex(index *content.Index, opts Options) {
+	if opts.DryRun {
+		return
+	}
+	if err := index.Save(); err != nil {
+		log.Debugf("%s", err)
+	}
+}
+
+// True if the local index shows the plaintext file has not changed since it matched the secret in the vault
+func unchangedInIndex(ctx content.Context, index *content.Index, filemeta content.Filemeta) bool {
+	entry, ok := index.Get(filemeta)
+	return ok && entry.Unchanged(ctx, filemeta)
+}
+
+// Like Datafile.MatchesCurrent, but the plaintext is compared to the hash in the local index before decrypting
+// the secret.  The index is updated if the plaintext matches.
+func matchesCurrent(ctx content.Context, index *content.Index, filemeta content.Filemeta, datafile content.Datafile) (bool, error) {
+	plaintext, err := datafile.DecodeData()
+	if err != nil {
+		return false, err
+	}
+	if entry, ok := index.Get(filemeta); ok && entry.Matches(ctx, filemeta, plaintext, datafile.Perm()) {
+		index.Record(ctx, filemeta, plaintext, entry.Perm, entry.Meta)
+		return true, nil
+	}
+
+	vaulted, err := content.ReadDatafile(ctx, filemeta)
+	if err != nil {
+		return false, err
+	}
+	if !vaulted.Equal(datafile) {
+		return false, nil
+	}
+	index.Record(ctx, filemeta, plaintext, vaulted.Perm(), vaulted.Meta())
+	return true, nil
+}
@@ -28,6 +28,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	c "github.com/jswidler/lockgit/pkg/content"
 	"github.com/jswidler/lockgit/pkg/log"
@@ -35,86 +36,122 @@ import (
 	"github.com/pkg/errors"
 )
 
-func openFromVault(ctx c.Context, filemeta c.Filemeta, params Options) error {
+const (
+	reasonUnchanged = "file exists and is unchanged"
+	reasonChanged   = "file exists but has changed"
+)
+
+// Decrypt a secret into its plaintext file.  Nothing is logged except debug traces, so secrets can be opened in
+// parallel; use logOpened to report the change afterwards.
+func openFromVault(ctx c.Context, index *c.Index, filemeta c.Filemeta, params Options) (FileChange, error) {
+	change := FileChange{Path: filemeta.RelPath, Action: ActionOpened}
+	if !params.Force && unchangedInIndex(ctx, index, filemeta) {
+		change.Action = ActionSkipped
+		change.Reason = reasonUnchanged
+		return change, nil
+	}
 	if !params.Force {
 		datafile, err := c.NewDatafile(ctx, filemeta.AbsPath)
 		if err == nil {
 			// Able to read the file
-			matches, err := datafile.MatchesCurrent(filemeta)
+			matches, err := matchesCurrent(ctx, index, filemeta, datafile)
 			if err != nil {
-				return err
+				return change, err
 			}
+			change.Action = ActionSkipped
 			if matches {
-				log.Verbose(fmt.Sprintf("skipping %s - file exists and is unchanged",
-					ctx.RelPath(filemeta.AbsPath)))
-				return nil
+				change.Reason = reasonUnchanged
 			} else {
-				log.Info(fmt.Sprintf("skipping %s - file exists but has changed.  To discard live version enable --force",
-					ctx.RelPath(filemeta.AbsPath)))
-				return nil
+				change.Reason = reasonChanged
 			}
+			return change, nil
 		} else if !os.IsNotExist(err) {
 			// Not really sure what happened
-			return err
+			return change, err
 		}
 	}
 	// if here, the file does not exist, or force is enabled
 	datafile, err := c.ReadDatafile(ctx, filemeta)
 	if err != nil {
-		return err
+		return change, err
 	}
 	data, err := datafile.DecodeData()
 	if err != nil {
-		return err
+		return change, err
 	}
 	absPath := filepath.Join(ctx.ProjectPath, datafile.Path())
+	if params.DryRun {
+		return change, nil
+	}
+	log.Debugf("writing %s", ctx.ProjRelPath(absPath))
 	_ = os.MkdirAll(filepath.Dir(absPath), 0755)
 	err = ioutil.WriteFile(absPath, data, os.FileMode(datafile.Perm()))
 	if err != nil {
-		return err
+		return change, err
 	}
-	log.Verbose(fmt.Sprintf("saved secret to %s", ctx.RelPath(absPath)))
-	return nil
+	index.Record(ctx, filemeta, data, datafile.Perm(), datafile.Meta())
+	return change, nil
 }
 
-func deletePlaintextFile(ctx c.Context, filemeta c.Filemeta, params Options) error {
+// Report a change made by openFromVault
+func logOpened(ctx c.Context, change FileChange, params Options) {
+	relPath := ctx.RelPath(filepath.Join(ctx.ProjectPath, change.Path))
+	switch {
+	case change.Reason == reasonUnchanged:
+		log.Verbosef("skipping %s - file exists and is unchanged", relPath)
+	case change.Reason == reasonChanged:
+		log.Warnf("skipping %s - file exists but has changed.  To discard live version enable --force", relPath)
+	case params.DryRun:
+		log.Verbosef("would save secret to %s", relPath)
+	default:
+		log.Verbosef("saved secret to %s", relPath)
+	}
+}
+
+// Delete the plaintext version of a file.  Returns true if the file was deleted.
+func deletePlaintextFile(ctx c.Context, index *c.Index, filemeta c.Filemeta, params Options) (bool, error) {
 	exists, err := u.Exists(filemeta.AbsPath)
 	if err != nil {
-		return err
+		return false, err
 	} else if !exists {
-		return nil
+		return false, nil
 	}
 
-	if !params.Force {
+	if !params.Force && !unchangedInIndex(ctx, index, filemeta) {
 		datafile, err := c.NewDatafile(ctx, filemeta.AbsPath)
 		if err != nil {
-			return err
+			return false, err
 		}
 
-		matches, err := datafile.MatchesCurrent(filemeta)
+		matches, err := matchesCurrent(ctx, index, filemeta, datafile)
 		if err != nil {
-			return err
+			return false, err
 		} else if !matches {
-			return fmt.Errorf("%s has changed.  To delete anyway enable --force\n", ctx.RelPath(filemeta.AbsPath))
+			return false, fmt.Errorf("%s has changed.  To delete anyway enable --force\n", ctx.RelPath(filemeta.AbsPath))
 		}
 	}
+	if params.DryRun {
+		return true, nil
+	}
+	log.Debugf("deleting %s", filemeta.RelPath)
 	err = os.Remove(filemeta.AbsPath)
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("could not delete %s", ctx.RelPath(filemeta.AbsPath)))
+		return false, errors.Wrap(err, fmt.Sprintf("could not delete %s", ctx.RelPath(filemeta.AbsPath)))
 	}
-	return nil
+	if entry, ok := index.Get(filemeta); ok && entry.SecretCurrent(ctx, filemeta) {
+		// keep the permissions and metadata of the secret for status
+		index.Record(ctx, filemeta, nil, entry.Perm, entry.Meta)
+	}
+	return true, nil
 }
 
 func addFile(ctx c.Context, manifest *c.Manifest, absPath string, opts Options) error {
-	relPath := ctx.ProjRelPath(absPath)
-	relRoot := strings.Split(relPath, string(os.PathSeparator))[0]
-	if relRoot == ".." {
-		return fmt.Errorf("%s cannot be added because it is not in the project directory %s", ctx.RelPath(absPath), ctx.RelPath(ctx.ProjectPath))
-	} else if relRoot == ".lockgit" {
-		return fmt.Errorf("%s cannot be added because it is in the .lockgit directory", ctx.RelPath(absPath))
+	err := checkAddable(ctx, absPath)
+	if err != nil {
+		return err
 	}
 
-	mindx := manifest.Find(relPath)
+	mindx := manifest.Find(ctx.ProjRelPath(absPath))
 	if !opts.Force && mindx >= 0 {
 		return fmt.Errorf("%s is already in the vault - enable --force or use commit to update instead", ctx.RelPath(absPath))
 	}
@@ -123,25 +160,117 @@ func addFile(ctx c.Context, manifest *c.Manifest, absPath string, opts Options)
 	if err != nil {
 		return err
 	}
-	filemeta := c.NewFilemeta(absPath, datafile)
-
-	err = datafile.Write(filemeta)
+	var current *c.Filemeta
+	if mindx >= 0 {
+		current = &manifest.Files[mindx]
+	}
+	filemeta, err := writeDatafile(ctx, nil, datafile, current, opts)
 	if err != nil {
 		return err
 	}
+	updateManifest(ctx, manifest, mindx, filemeta, opts)
+	return nil
+}
+
+// Encrypt a file in the manifest again if it has changed.  Returns the new entry for the manifest, or an
+// empty entry if the file is closed or unchanged.
+func commitFile(ctx c.Context, index *c.Index, filemeta c.Filemeta, opts Options) (c.Filemeta, error) {
+	if unchangedInIndex(ctx, index, filemeta) {
+		return c.Filemeta{}, nil
+	}
+	datafile, err := c.NewDatafile(ctx, filemeta.AbsPath)
+	if os.IsNotExist(err) {
+		// the plaintext file is closed, so there is nothing to commit
+		return c.Filemeta{}, nil
+	} else if err != nil {
+		return c.Filemeta{}, err
+	}
+	matches, err := matchesCurrent(ctx, index, filemeta, datafile)
+	if err != nil || matches {
+		return c.Filemeta{}, err
+	}
+	return writeDatafile(ctx, index, datafile, &filemeta, opts)
+}
+
+// Encrypt a file matched by a pattern which is not in the manifest yet.  Returns the new entry for the manifest.
+func commitNewFile(ctx c.Context, index *c.Index, absPath string, opts Options) (c.Filemeta, error) {
+	err := checkAddable(ctx, absPath)
+	if err != nil {
+		return c.Filemeta{}, err
+	}
+	datafile, err := c.NewDatafile(ctx, absPath)
+	if err != nil {
+		return c.Filemeta{}, err
+	}
+	return writeDatafile(ctx, index, datafile, nil, opts)
+}
+
+// Returns an error if the file cannot be added to the vault because of where it is
+func checkAddable(ctx c.Context, absPath string) error {
+	relRoot := strings.Split(ctx.ProjRelPath(absPath), string(os.PathSeparator))[0]
+	if relRoot == ".." {
+		return fmt.Errorf("%s cannot be added because it is not in the project directory %s", ctx.RelPath(absPath), ctx.RelPath(ctx.ProjectPath))
+	} else if relRoot == ".lockgit" {
+		return fmt.Errorf("%s cannot be added because it is in the .lockgit directory", ctx.RelPath(absPath))
+	}
+	return nil
+}
 
+// Encrypt a file and write its datafile to the vault.  If the file is already in the vault, current is its
+// entry in the manifest, and the metadata of the secret is kept.  Returns the new entry for the manifest, which
+// is recorded in the local index.  This does not change the manifest, so it is safe to call for several files
+// at once.
+func writeDatafile(ctx c.Context, index *c.Index, datafile c.Datafile, current *c.Filemeta, opts Options) (c.Filemeta, error) {
+	// the metadata stays the same when a secret is updated, and it is only counted as rotated if its contents
+	// have changed
+	meta := c.Metadata{}
+	changed := true
+	if current != nil {
+		if vaulted, err := c.ReadDatafile(ctx, *current); err == nil {
+			meta = vaulted.Meta()
+			changed = !vaulted.SameData(datafile)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if meta.Created.IsZero() {
+		meta.Created = now
+	}
+	if changed || meta.Updated.IsZero() {
+		meta.Updated = now
+	}
+	if opts.RotateEvery != "" {
+		meta.RotateEvery = opts.RotateEvery
+	}
+	datafile.SetMeta(meta)
+	filemeta := c.NewFilemeta(filepath.Join(ctx.ProjectPath, datafile.Path()), datafile)
+
+	if !opts.DryRun {
+		err := datafile.Write(filemeta)
+		if err != nil {
+			return filemeta, err
+		}
+		if plaintext, err := datafile.DecodeData(); err == nil {
+			index.Record(ctx, filemeta, plaintext, datafile.Perm(), meta)
+		}
+	}
+	return filemeta, nil
+}
+
+// Put an entry returned by writeDatafile in the manifest.  If mindx is not negative, the entry replaces the
+// one at that index and its old datafile is deleted.
+func updateManifest(ctx c.Context, manifest *c.Manifest, mindx int, filemeta c.Filemeta, opts Options) {
 	if mindx >= 0 {
-		oldDatafile := c.MakeDatafilePath(ctx, manifest.Files[mindx])
-		_ = os.Remove(oldDatafile)
+		if !opts.DryRun {
+			oldDatafile := c.MakeDatafilePath(ctx, manifest.Files[mindx])
+			_ = os.Remove(oldDatafile)
+		}
 		manifest.Files[mindx] = filemeta
 	} else {
 		manifest.Add(filemeta)
 	}
-
-	return nil
 }
 
-func deleteFileFromVault(ctx c.Context, manifest *c.Manifest, absPath string) error {
+func deleteFileFromVault(ctx c.Context, manifest *c.Manifest, absPath string, opts Options) error {
 	relPath, err := filepath.Rel(ctx.ProjectPath, absPath)
 	if err != nil {
 		return err
@@ -151,15 +280,17 @@ func deleteFileFromVault(ctx c.Context, manifest *c.Manifest, absPath string) er
 		return fmt.Errorf("not found in manifest %s", relPath)
 	}
 
-	datafilepath := c.MakeDatafilePath(ctx, manifest.Files[mindx])
-	_ = os.Remove(datafilepath)
+	if !opts.DryRun {
+		datafilepath := c.MakeDatafilePath(ctx, manifest.Files[mindx])
+		_ = os.Remove(datafilepath)
+	}
 	manifest.Files = append(manifest.Files[:mindx], manifest.Files[mindx+1:]...)
 	return nil
 }
 
 func ensureSameContext(ctx c.Context, files []string) error {
 	for _, filename := range files {
-		fileCtx, _ := c.FromPath(filename)
+		fileCtx, _ := c.FromPath(filename, ctx.KeyStore)
 		if ctx.LockgitPath != fileCtx.LockgitPath {
 			// One of the files is in a different vault from the original
 			if fileCtx.LockgitPath == "" {
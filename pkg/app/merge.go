@@ -0,0 +1,261 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/git"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// A secret which was changed differently on both sides of a merge
+type MergeConflict struct {
+	Path     string
+	Versions []string // paths to the decrypted versions written for resolution, if the key is present
+}
+
+// Perform a three-way merge of manifests, as a git merge driver.  The base, ours and theirs arguments are
+// the paths to the common ancestor, current and other versions of the manifest, and the result is written
+// over ours.  mergedPath is the path of the manifest in the work tree, which is used to find the vault and
+// the profile the manifest belongs to.
+//
+// Each secret is merged independently, so changes to different secrets never conflict.  If both sides
+// changed the same secret differently, it is returned as a conflict and our version is kept in the
+// merged manifest.  When the key is present, the decrypted base, ours and theirs versions of each
+// conflicting secret are written inside the .git directory so the conflict can be resolved.
+func MergeManifests(opts Options, base, ours, theirs, mergedPath string) ([]MergeConflict, error) {
+	if mergedPath == "" {
+		mergedPath = filepath.Join(".lockgit", "manifest")
+	}
+	projectPath, profile := manifestVault(mergedPath)
+	paths := []string{projectPath}
+	pathsToAbs(opts.Wd, &paths)
+	ctx, _, err := loadcm(paths[0], loadcmopts{ctxOnly: true, profile: profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]content.Manifest, 3)
+	for i, path := range []string{base, ours, theirs} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read manifest")
+		}
+		manifests[i], err = content.ParseManifest(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	merged, conflicts := mergeManifests(manifests[0], manifests[1], manifests[2])
+
+	err = ioutil.WriteFile(ours, merged.Serialize(), 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to write merged manifest")
+	}
+
+	if len(conflicts) > 0 && ctx.Key != nil {
+		for i := range conflicts {
+			conflicts[i].Versions, err = writeConflictVersions(ctx, conflicts[i].Path, manifests)
+			if err != nil {
+				log.LogError(errors.Wrapf(err, "unable to decrypt versions of %s", conflicts[i].Path))
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// Returns the project directory and profile of a manifest from its path, which is either .lockgit/manifest or
+// .lockgit/profiles/<profile>/manifest in the project
+func manifestVault(manifestPath string) (string, string) {
+	dir := filepath.Dir(manifestPath)
+	if profiles := filepath.Dir(dir); filepath.Base(profiles) == "profiles" && filepath.Base(filepath.Dir(profiles)) == ".lockgit" {
+		return filepath.Dir(filepath.Dir(profiles)), filepath.Base(dir)
+	}
+	return filepath.Dir(dir), ""
+}
+
+func mergeManifests(base, ours, theirs content.Manifest) (content.Manifest, []MergeConflict) {
+	merged := content.Manifest{Files: make([]content.Filemeta, 0, len(ours.Files))}
+	conflicts := make([]MergeConflict, 0)
+
+	paths := make(map[string]bool)
+	for _, m := range []content.Manifest{base, ours, theirs} {
+		for _, f := range m.Files {
+			paths[f.RelPath] = true
+		}
+	}
+
+	for path := range paths {
+		b, o, t := entryId(base, path), entryId(ours, path), entryId(theirs, path)
+		var keep content.Manifest
+		switch {
+		case o == t, t == b:
+			keep = ours
+		case o == b:
+			keep = theirs
+		default:
+			conflicts = append(conflicts, MergeConflict{Path: path})
+			if o != "" {
+				keep = ours
+			} else {
+				keep = theirs
+			}
+		}
+		if i := keep.Find(path); i >= 0 {
+			merged.Add(keep.Files[i])
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	return merged, conflicts
+}
+
+func entryId(m content.Manifest, path string) string {
+	if i := m.Find(path); i >= 0 {
+		return m.Files[i].IdString()
+	}
+	return ""
+}
+
+// Decrypt each side of a conflicting secret into .git/lockgit/merge so they can be compared
+func writeConflictVersions(ctx content.Context, path string, manifests []content.Manifest) ([]string, error) {
+	gitDir, err := git.GitDir(ctx.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
+	// the paths come from the manifests being merged, so they must not be trusted to stay in the merge directory
+	mergeDir := filepath.Join(gitDir, "lockgit", "merge")
+	if !strings.HasPrefix(filepath.Join(mergeDir, path), mergeDir+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is not a valid path for a secret", path)
+	}
+	versions := make([]string, 0, 3)
+	for i, side := range []string{"base", "ours", "theirs"} {
+		mindx := manifests[i].Find(path)
+		if mindx < 0 {
+			continue
+		}
+		data, err := findSecret(ctx, manifests[i].Files[mindx])
+		if err != nil {
+			return versions, err
+		}
+		out := filepath.Join(mergeDir, path+"."+side)
+		_ = os.MkdirAll(filepath.Dir(out), 0700)
+		err = ioutil.WriteFile(out, data, 0600)
+		if err != nil {
+			return versions, err
+		}
+		versions = append(versions, out)
+	}
+	return versions, nil
+}
+
+// Read and decrypt a secret from the working directory, or from whichever commit added its datafile
+// if it has not been checked out.
+func findSecret(ctx content.Context, filemeta content.Filemeta) ([]byte, error) {
+	datafile, err := content.ReadDatafile(ctx, filemeta)
+	if os.IsNotExist(err) {
+		datafilePath := ctx.ProjRelPath(content.MakeDatafilePath(ctx, filemeta))
+		commit, err := git.FindAdded(ctx.ProjectPath, datafilePath)
+		if err != nil {
+			return nil, fmt.Errorf("datafile for %s not found", filemeta.RelPath)
+		}
+		revCtx := ctx
+		revCtx.Source = git.RevSource{Dir: ctx.ProjectPath, Rev: commit}
+		datafile, err = content.ReadDatafile(revCtx, filemeta)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return datafile.DecodeData()
+}
+
+// Register the lockgit git integrations with the repository the vault is in
+func InstallGitIntegration(opts Options) error {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return err
+	}
+
+	if !git.IsRepo(ctx.ProjectPath) {
+		return fmt.Errorf("%s is not in a git repository", ctx.RelPath(ctx.ProjectPath))
+	}
+
+	for _, integration := range gitIntegrations {
+		for key, value := range integration.config {
+			err := git.SetConfig(ctx.ProjectPath, key, value)
+			if err != nil {
+				return err
+			}
+		}
+		changed, err := git.AddAttribute(ctx.ProjectPath, integration.pattern, integration.attribute)
+		if err != nil {
+			return errors.Wrap(err, "unable to update .gitattributes")
+		}
+		if changed {
+			log.Infof("added '%s %s' to .gitattributes", integration.pattern, integration.attribute)
+		}
+		log.Infof("registered %s", integration.name)
+	}
+	return nil
+}
+
+type gitIntegration struct {
+	name      string
+	pattern   string // pattern in .gitattributes, relative to the project
+	attribute string
+	config    map[string]string
+}
+
+var gitIntegrations = []gitIntegration{
+	{
+		name:      "merge driver for .lockgit/manifest",
+		pattern:   ".lockgit/manifest",
+		attribute: "merge=lockgit",
+		config: map[string]string{
+			"merge.lockgit.name":   "lockgit manifest merge driver",
+			"merge.lockgit.driver": "lockgit merge-driver %O %A %B %P",
+		},
+	},
+	{
+		name:      "merge driver for the manifests of profiles",
+		pattern:   ".lockgit/profiles/*/manifest",
+		attribute: "merge=lockgit",
+	},
+	{
+		name:      "diff driver for .lockgit/data",
+		pattern:   ".lockgit/data/*",
+		attribute: "diff=lockgit",
+		config: map[string]string{
+			"diff.lockgit.textconv": "lockgit textconv",
+		},
+	},
+}
@@ -0,0 +1,187 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package app
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/jswidler/lockgit/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// A secret in the vault along with its metadata
+type SecretRecord struct {
+	Path             string `json:"path" yaml:"path"`
+	Id               string `json:"id" yaml:"id"`
+	content.Metadata `yaml:",inline"`
+}
+
+// Changes to make to the metadata of secrets.  Fields which are nil are left alone.
+type Annotation struct {
+	Description *string
+	Owner       *string
+	AddTags     []string
+	RemoveTags  []string
+	RotateEvery *string    // "" stops rotation reminders
+	Expires     *time.Time // the zero time removes the expiry date
+}
+
+// Returns the secrets in the vault with their metadata.  Secrets which cannot be decrypted are returned
+// without metadata, along with a PartialError.
+func LsLong(opts Options) ([]SecretRecord, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	files, errs := filterTags(ctx, manifest.Files, opts.Tags)
+	records := make([]SecretRecord, 0, len(files))
+	for _, filemeta := range files {
+		record := SecretRecord{Path: filemeta.RelPath, Id: filemeta.IdString()}
+		meta, err := readMeta(ctx, filemeta)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		record.Metadata = meta
+		records = append(records, record)
+	}
+	if len(errs) > 0 {
+		return records, &PartialError{Errors: errs}
+	}
+	return records, nil
+}
+
+// Change the metadata of the secrets matching one of the paths or glob patterns, or of every secret if
+// there are no paths.  Like a change to the contents, each annotated secret gets a new datafile, so the change
+// shows in the manifest for log and for merges.
+func Annotate(opts Options, paths []string, annotation Annotation) (changes []FileChange, err error) {
+	if annotation.RotateEvery != nil && *annotation.RotateEvery != "" {
+		if _, err := util.ParseDuration(*annotation.RotateEvery); err != nil {
+			return nil, err
+		}
+	}
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil || isEmpty(manifest) {
+		return []FileChange{}, err
+	}
+	changes = make([]FileChange, 0, len(manifest.Files))
+	index := readIndex(ctx, opts)
+	defer saveIndex(index, opts)
+	manifestChange := false
+	defer func() { saveChanges(ctx, opts, manifest, manifestChange, false, &err) }()
+
+	selected, errs := selectFiles(ctx, manifest, paths, opts.Exclude, opts.Tags)
+	for _, filemeta := range selected {
+		datafile, err := content.ReadDatafile(ctx, filemeta)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "unable to read %s", filemeta.RelPath))
+			continue
+		}
+		meta := annotation.apply(datafile.Meta())
+		if reflect.DeepEqual(meta, datafile.Meta()) {
+			log.Verbosef("'%s' already has the metadata", ctx.RelPath(filemeta.AbsPath))
+			continue
+		}
+		datafile.SetMeta(meta)
+		annotated := content.NewFilemeta(filemeta.AbsPath, datafile)
+		if !opts.DryRun {
+			if err := datafile.Write(annotated); err != nil {
+				errs = append(errs, errors.Wrapf(err, "unable to write %s", filemeta.RelPath))
+				continue
+			}
+			// the plaintext still matches if it did before
+			var plaintext []byte
+			if unchangedInIndex(ctx, index, filemeta) {
+				plaintext, _ = datafile.DecodeData()
+			}
+			index.Record(ctx, annotated, plaintext, datafile.Perm(), meta)
+		}
+		updateManifest(ctx, &manifest, manifest.Find(filemeta.RelPath), annotated, opts)
+		manifestChange = true
+		logChange(opts, "annotated '%s'", "would annotate '%s'", ctx.RelPath(filemeta.AbsPath))
+		changes = append(changes, FileChange{Path: filemeta.RelPath, Action: ActionAnnotated})
+	}
+	if len(errs) > 0 {
+		return changes, &PartialError{Errors: errs}
+	}
+	return changes, nil
+}
+
+func (a Annotation) apply(meta content.Metadata) content.Metadata {
+	if a.Description != nil {
+		meta.Description = *a.Description
+	}
+	if a.Owner != nil {
+		meta.Owner = *a.Owner
+	}
+	if a.RotateEvery != nil {
+		meta.RotateEvery = *a.RotateEvery
+	}
+	if a.Expires != nil {
+		meta.Expires = nil
+		if !a.Expires.IsZero() {
+			expires := a.Expires.UTC()
+			meta.Expires = &expires
+		}
+	}
+	tags := append(append([]string(nil), meta.Tags...), a.AddTags...)
+	tags = util.Filter(tags, func(tag string) bool {
+		for _, removed := range a.RemoveTags {
+			if tag == removed {
+				return false
+			}
+		}
+		return true
+	})
+	tags = util.Unique(tags)
+	sort.Strings(tags)
+	meta.Tags = tags
+	return meta
+}
+
+// Returns the metadata of a secret
+func readMeta(ctx content.Context, filemeta content.Filemeta) (content.Metadata, error) {
+	datafile, err := content.ReadDatafile(ctx, filemeta)
+	if err != nil {
+		return content.Metadata{}, errors.Wrapf(err, "unable to read %s", filemeta.RelPath)
+	}
+	return datafile.Meta(), nil
+}
+
+// Returns the files which have all of the tags.  Files whose metadata cannot be read are left out, with an error.
+func filterTags(ctx content.Context, files []content.Filemeta, tags []string) ([]content.Filemeta, []error) {
+	if len(tags) == 0 {
+		return files, nil
+	}
+	var errs []error
+	filtered := make([]content.Filemeta, 0, len(files))
+	for _, filemeta := range files {
+		meta, err := readMeta(ctx, filemeta)
+		if err != nil {
+			errs = append(errs, err)
+		} else if meta.HasTags(tags) {
+			filtered = append(filtered, filemeta)
+		}
+	}
+	return filtered, errs
+}
@@ -0,0 +1,105 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package app
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// A profile of the vault
+type ProfileRecord struct {
+	Name   string `json:"name" yaml:"name"`
+	HasKey bool   `json:"hasKey" yaml:"hasKey"` // true if the key for the profile is saved in the config file
+	Files  int    `json:"files" yaml:"files"`   // the number of secrets in the profile
+}
+
+// The names are used in paths and in the keys of the key stores, such as nested settings in the config file,
+// so they cannot contain dots
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Add a profile to the vault with a new key.  A profile has its own key, secrets and glob patterns, so it can
+// be opened by someone who does not have the keys for the rest of the vault.
+func AddProfile(opts Options, name string) (KeyRecord, error) {
+	if !profileNameRegexp.MatchString(name) {
+		return KeyRecord{}, fmt.Errorf("invalid profile name '%s': use letters, numbers, '_' and '-'", name)
+	}
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, keyStore: opts.KeyStore})
+	if err != nil {
+		return KeyRecord{}, err
+	}
+	if ctx.Config.HasProfile(name) {
+		return KeyRecord{}, fmt.Errorf("the vault already has a profile named %s", name)
+	}
+
+	err = os.MkdirAll(content.ProfilePath(ctx, name), 0755)
+	if err != nil {
+		return KeyRecord{}, errors.Wrap(err, "failed to make profile directory")
+	}
+	ctx.Config.Profiles = append(ctx.Config.Profiles, name)
+	sort.Strings(ctx.Config.Profiles)
+	err = ctx.Config.Write(ctx.ConfigPath)
+	if err != nil {
+		return KeyRecord{}, err
+	}
+
+	profileCtx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: name, keyStore: opts.KeyStore})
+	if err != nil {
+		return KeyRecord{}, err
+	}
+	err = profileCtx.Config.Write(profileCtx.ConfigPath)
+	if err != nil {
+		return KeyRecord{}, err
+	}
+	err = saveKey(profileCtx, keyToString(genKey()))
+	if err != nil {
+		return KeyRecord{}, err
+	}
+
+	log.Infof("Added profile %s to the vault in %s\nKey added to %s", name, ctx.LockgitPath, ctx.KeyStore)
+	return keyRecord(profileCtx), nil
+}
+
+// Returns the profiles of the vault, sorted by name
+func ListProfiles(opts Options) ([]ProfileRecord, error) {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ProfileRecord, 0, len(ctx.Config.Profiles))
+	for _, name := range ctx.Config.Profiles {
+		profileCtx, manifest, err := loadcm(opts.Wd, loadcmopts{profile: name, keyStore: opts.KeyStore})
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, ProfileRecord{
+			Name:   name,
+			HasKey: profileCtx.Key != nil,
+			Files:  len(manifest.Files),
+		})
+	}
+	return records, nil
+}
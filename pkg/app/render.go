@@ -0,0 +1,251 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/gitignore"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Rendered files may contain secrets, so only the owner can read them
+const renderedPerm os.FileMode = 0600
+
+// Resolves the template functions to secrets in the vault.  Secrets are decrypted at most once.
+type renderer struct {
+	ctx      content.Context
+	manifest content.Manifest
+	secrets  map[string][]byte
+}
+
+// Render a Go text/template with the contents of secrets in the vault.  Paths in the template are relative to
+// the project.  The template can use these functions:
+//
+//	secret "path"           the contents of a secret
+//	json "path" "a.b.0.c"   a value from a JSON secret, found by following the keys and array indexes
+//	kv "KEY" ["path" ...]   the value of KEY=VALUE in a secret, searching every secret if no paths are given
+func Render(opts Options, templatePath string) ([]byte, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, rev: opts.Rev, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{templatePath}
+	pathsToAbs(ctx.WorkingPath, &paths)
+	text, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		return nil, err
+	}
+
+	r := renderer{ctx: ctx, manifest: manifest, secrets: make(map[string][]byte)}
+	tmpl, err := template.New(filepath.Base(paths[0])).Option("missingkey=error").Funcs(template.FuncMap{
+		"secret": r.secret,
+		"json":   r.json,
+		"kv":     r.kv,
+	}).Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, nil)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Render a template to a file which only the owner can read.  If register is true, the file is saved in the
+// vault's config so close deletes it, and it is added to the .gitignore file.
+func RenderToFile(opts Options, templatePath, outPath string, register bool) (FileChange, error) {
+	data, err := Render(opts, templatePath)
+	if err != nil {
+		return FileChange{}, err
+	}
+
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return FileChange{}, err
+	}
+	paths := []string{outPath}
+	pathsToAbs(ctx.WorkingPath, &paths)
+	absPath := paths[0]
+	relPath := ctx.ProjRelPath(absPath)
+	change := FileChange{Path: relPath, Action: ActionRendered}
+	if register && strings.HasPrefix(relPath, "..") {
+		return change, fmt.Errorf("cannot register %s: it is not in the project", outPath)
+	}
+	if opts.DryRun {
+		log.Infof("would render %s", ctx.RelPath(absPath))
+		return change, nil
+	}
+
+	_ = os.MkdirAll(filepath.Dir(absPath), 0755)
+	err = ioutil.WriteFile(absPath, data, renderedPerm)
+	if err != nil {
+		return change, err
+	}
+	// WriteFile does not change the permissions of a file which already exists
+	err = os.Chmod(absPath, renderedPerm)
+	if err != nil {
+		return change, err
+	}
+	log.Info(fmt.Sprintf("rendered %s", ctx.RelPath(absPath)))
+
+	if register {
+		if !opts.NoUpdateGitignore {
+			if err := gitignore.Add(ctx.ProjectPath, "/"+filepath.ToSlash(relPath)); err != nil {
+				return change, err
+			}
+		}
+		if added := ctx.Config.AddRendered(relPath); added {
+			return change, ctx.Config.Write(ctx.ConfigPath)
+		}
+	}
+	return change, nil
+}
+
+// Delete the registered rendered files.  Files which have already been deleted are ignored.
+func deleteRenderedFiles(ctx content.Context, opts Options) ([]FileChange, []error) {
+	var changes []FileChange
+	var errs []error
+	for _, relPath := range ctx.Config.Rendered {
+		absPath := filepath.Join(ctx.ProjectPath, relPath)
+		if _, err := os.Lstat(absPath); os.IsNotExist(err) {
+			continue
+		}
+		if !opts.DryRun {
+			if err := os.Remove(absPath); err != nil {
+				errs = append(errs, errors.Wrapf(err, "could not delete %s", ctx.RelPath(absPath)))
+				continue
+			}
+		}
+		changes = append(changes, FileChange{Path: relPath, Action: ActionClosed})
+	}
+	return changes, errs
+}
+
+func (r renderer) secret(path string) (string, error) {
+	data, err := r.read(path)
+	return string(data), err
+}
+
+func (r renderer) json(path, keys string) (string, error) {
+	data, err := r.read(path)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", errors.Wrapf(err, "%s is not valid json", path)
+	}
+	for _, key := range strings.Split(keys, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("%s does not have %s", path, keys)
+			}
+			value = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("%s does not have %s", path, keys)
+			}
+			value = node[i]
+		default:
+			return "", fmt.Errorf("%s does not have %s", path, keys)
+		}
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	out, err := json.Marshal(value)
+	return string(out), err
+}
+
+func (r renderer) kv(key string, paths ...string) (string, error) {
+	if len(paths) == 0 {
+		for _, filemeta := range r.manifest.Files {
+			paths = append(paths, filemeta.RelPath)
+		}
+	}
+	for _, path := range paths {
+		data, err := r.read(path)
+		if err != nil {
+			return "", err
+		}
+		if value, ok := lookupKey(data, key); ok {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%s was not found in the vault", key)
+}
+
+// Reads a secret given its path relative to the project
+func (r renderer) read(path string) ([]byte, error) {
+	relPath := filepath.Clean(path)
+	if data, ok := r.secrets[relPath]; ok {
+		return data, nil
+	}
+	mindx := r.manifest.Find(relPath)
+	if mindx < 0 {
+		return nil, fmt.Errorf("%s is not in the vault", path)
+	}
+	datafile, err := content.ReadDatafile(r.ctx, r.manifest.Files[mindx])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", relPath)
+	}
+	data, err := datafile.DecodeData()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", relPath)
+	}
+	r.secrets[relPath] = data
+	return data, nil
+}
+
+// Find the value of a KEY=VALUE line, like those in a .env file
+func lookupKey(data []byte, key string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "export ")
+		eq := strings.Index(line, "=")
+		if strings.HasPrefix(line, "#") || eq < 0 || strings.TrimSpace(line[:eq]) != key {
+			continue
+		}
+		value := strings.TrimSpace(line[eq+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value, true
+	}
+	return "", false
+}
@@ -0,0 +1,90 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"strings"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+)
+
+// What a command did to a file or glob pattern
+type Action string
+
+const (
+	ActionAdded          Action = "added"
+	ActionUpdated        Action = "updated"
+	ActionRemoved        Action = "removed"
+	ActionMoved          Action = "moved"
+	ActionOpened         Action = "opened"
+	ActionClosed         Action = "closed"
+	ActionSkipped        Action = "skipped"
+	ActionPatternAdded   Action = "pattern added"
+	ActionPatternRemoved Action = "pattern removed"
+	ActionRendered       Action = "rendered"
+	ActionAnnotated      Action = "annotated"
+)
+
+// A change made to the vault or to a plaintext file by a command
+type FileChange struct {
+	Path   string `json:"path" yaml:"path"`                     // relative to the project, or the glob pattern
+	From   string `json:"from,omitempty" yaml:"from,omitempty"` // the old path of a secret which was moved
+	Action Action `json:"action" yaml:"action"`
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// The vault whose key was created, saved or deleted by a command
+type KeyRecord struct {
+	Id       string `json:"id" yaml:"id"`     // the id of the vault, which identifies its key in the key store
+	Path     string `json:"path" yaml:"path"` // the project directory of the vault
+	Profile  string `json:"profile,omitempty" yaml:"profile,omitempty"`
+	KeyStore string `json:"keyStore" yaml:"keyStore"` // where the key is saved
+}
+
+// Log a change made by a command.  Nothing is changed in a dry run, so dryRunFormat describes what would be done.
+func logChange(opts Options, format, dryRunFormat string, args ...interface{}) {
+	if opts.DryRun {
+		format = dryRunFormat
+	}
+	log.Infof(format, args...)
+}
+
+func keyRecord(ctx content.Context) KeyRecord {
+	return KeyRecord{Id: ctx.Config.Id, Path: ctx.ProjectPath, Profile: ctx.Profile, KeyStore: ctx.KeyStore.String()}
+}
+
+// Returned when a command could not process every file.  Other files may have been processed successfully.
+type PartialError struct {
+	Errors []error
+}
+
+func (err *PartialError) Error() string {
+	messages := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		messages[i] = strings.TrimSpace(e.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (err *PartialError) ExitCode() int {
+	return log.ExitPartial
+}
@@ -0,0 +1,191 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/git"
+	"github.com/jswidler/lockgit/pkg/gitignore"
+	"github.com/jswidler/lockgit/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// A file which looks like it contains secrets but is not in the vault
+type ScanFinding struct {
+	Path    string   `json:"path" yaml:"path"` // relative to the project
+	Reasons []string `json:"reasons" yaml:"reasons"`
+}
+
+// Files larger than this are not read when scanning
+const scanMaxSize = 1 << 20
+
+var (
+	privateKeyRegexp   = regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)
+	awsAccessKeyRegexp = regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)
+	awsSecretKeyRegexp = regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+]{40}\b`)
+	// a value assigned to something named like a secret
+	tokenRegexp = regexp.MustCompile(`(?i)(secret|token|passw(or)?d|api_?key|credential|auth)[A-Za-z0-9_.-]*["']?\s*[:=]\s*["']?([A-Za-z0-9+/_.=-]{20,})`)
+)
+
+// Find files in the project which look like they contain credentials, but are not in the vault and are not
+// matched by one of its patterns.  In a git repository, files which git ignores are skipped.  Files which cannot
+// be read are reported with a PartialError after the rest of the project is scanned.
+func Scan(opts Options) ([]ScanFinding, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := scanFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]ScanFinding, 0)
+	errs := make([]error, 0)
+	for _, relPath := range files {
+		if manifest.Find(relPath) >= 0 || firstMatchedPattern(ctx.Config, relPath, ctx.Config.Patterns) != "" {
+			continue
+		}
+		reasons, err := scanFile(filepath.Join(ctx.ProjectPath, relPath))
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "unable to scan %s", relPath))
+			continue
+		}
+		if len(reasons) > 0 {
+			findings = append(findings, ScanFinding{Path: relPath, Reasons: reasons})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Path < findings[j].Path
+	})
+	if len(errs) > 0 {
+		return findings, &PartialError{Errors: errs}
+	}
+	return findings, nil
+}
+
+// Add files reported by Scan to the vault.  The paths are relative to the project.
+func AddScanned(opts Options, paths []string) ([]FileChange, error) {
+	ctx, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	absPaths := make([]string, len(paths))
+	for i, path := range paths {
+		absPaths[i] = filepath.Join(ctx.ProjectPath, path)
+	}
+	return AddToVault(opts, absPaths)
+}
+
+// Returns the files to scan, relative to the project
+func scanFiles(ctx content.Context) ([]string, error) {
+	if !git.IsRepo(ctx.ProjectPath) {
+		absPaths, err := gitignore.Files(ctx.ProjectPath, []string{"**"})
+		return projRelPaths(ctx, absPaths), err
+	}
+	files, err := git.ListFiles(ctx.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
+	// ls-files also lists the vault if it is committed
+	lockgitDir := ctx.ProjRelPath(ctx.LockgitPath) + string(os.PathSeparator)
+	return util.Filter(files, func(path string) bool {
+		return !strings.HasPrefix(path, lockgitDir)
+	}), nil
+}
+
+// Returns the reasons a file looks like it contains credentials, if any
+func scanFile(absPath string) ([]string, error) {
+	info, err := os.Lstat(absPath)
+	if os.IsNotExist(err) {
+		// tracked by git but deleted
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+	if isEnvFile(info.Name()) {
+		reasons = append(reasons, "environment file")
+	}
+	if !info.Mode().IsRegular() || info.Size() > scanMaxSize {
+		return reasons, nil
+	}
+	data, err := ioutil.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		// binary file
+		return reasons, nil
+	}
+
+	if privateKeyRegexp.Match(data) {
+		reasons = append(reasons, "private key")
+	}
+	if awsAccessKeyRegexp.Match(data) || awsSecretKeyRegexp.Match(data) {
+		reasons = append(reasons, "AWS credentials")
+	}
+	for _, match := range tokenRegexp.FindAllSubmatch(data, -1) {
+		if entropy(string(match[3])) >= 4.0 {
+			reasons = append(reasons, "high entropy token")
+			break
+		}
+	}
+	return reasons, nil
+}
+
+// True for .env files, but not for examples of them which are meant to be committed
+func isEnvFile(name string) bool {
+	if name != ".env" && !strings.HasPrefix(name, ".env.") && !strings.HasSuffix(name, ".env") {
+		return false
+	}
+	for _, suffix := range []string{".example", ".sample", ".template", ".dist"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// The Shannon entropy of a string in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var e float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		e -= p * math.Log2(p)
+	}
+	return e
+}
@@ -21,95 +21,228 @@
 package app
 
 import (
+	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
-	"strconv"
+	"time"
 
 	"github.com/jswidler/lockgit/pkg/content"
 	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/jswidler/lockgit/pkg/util"
 )
 
-// Returns (headers, rows) for a table
-func Status(opts Options) ([]string, [][]string) {
-	ctx, manifest := loadcm(opts.Wd, loadcmopts{})
-	headers := []string{"file", "updated", "pattern", "id"}
+// The state of a file in the vault compared to its plaintext version
+type FileState string
 
-	// Collect all the files which are tracked by patterns
-	patternMatched := make([]string, 0, 64)
-	if len(ctx.Config.Patterns) > 0 {
-		for _, pattern := range ctx.Config.Patterns {
-			absPattern := filepath.Join(ctx.ProjectPath, pattern)
-
-			_, files, _, err := util.GetFiles(absPattern)
-			log.FatalPanic(err)
-			patternMatched = append(patternMatched, files...)
+const (
+	StateUnchanged   FileState = "unchanged"
+	StateUpdated     FileState = "updated"
+	StateUnavailable FileState = "unavailable"
+	StateUnknown     FileState = "unable to compare"
+	StateNew         FileState = "new file"
+)
+
+// The status of one file that is in the vault or matched by one of its patterns
+type StatusRecord struct {
+	Path    string    `json:"path" yaml:"path"`
+	State   FileState `json:"state" yaml:"state"`
+	Pattern string    `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Id      string    `json:"id,omitempty" yaml:"id,omitempty"`
+	Perm    string    `json:"perm,omitempty" yaml:"perm,omitempty"`
+	Owner   string    `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Tags    []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Due     DueReason `json:"due,omitempty" yaml:"due,omitempty"` // set if the secret expires or should be rotated soon
+}
+
+// The value for the updated column of the status table
+func (r StatusRecord) Updated() string {
+	switch r.State {
+	case StateUnchanged:
+		return "false"
+	case StateUpdated:
+		return "true"
+	default:
+		return string(r.State)
+	}
+}
+
+// True if the file has changed, is not in the vault yet, or could not be compared
+func (r StatusRecord) Dirty() bool {
+	return r.State == StateUpdated || r.State == StateNew || r.State == StateUnknown
+}
+
+// Returned by CheckStatus when secrets have changed or are not committed to the vault
+type DirtyError struct {
+	Records []StatusRecord
+}
+
+func (err *DirtyError) Error() string {
+	if len(err.Records) == 1 {
+		return "1 secret has changed or is not committed to the vault"
+	}
+	return fmt.Sprintf("%d secrets have changed or are not committed to the vault", len(err.Records))
+}
+
+func (err *DirtyError) ExitCode() int {
+	return log.ExitDirty
+}
+
+// Returns the files which are dirty, and a DirtyError if there are any.  Unlike Status, the key is required
+// so that every file can be compared.
+func CheckStatus(opts Options) ([]StatusRecord, error) {
+	_, _, err := loadcm(opts.Wd, loadcmopts{ctxOnly: true, keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+	records, err := Status(opts)
+	if err != nil {
+		return nil, err
+	}
+	dirty := make([]StatusRecord, 0)
+	for _, record := range records {
+		if record.Dirty() {
+			dirty = append(dirty, record)
 		}
 	}
+	if len(dirty) > 0 {
+		return dirty, &DirtyError{Records: dirty}
+	}
+	return dirty, nil
+}
+
+// Returns the status of each file, sorted by path.  With tags, only the secrets in the vault which have all
+// of the tags are returned.
+func Status(opts Options) ([]StatusRecord, error) {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect all the files which are tracked by patterns
+	patternMatched, err := ctx.Config.PatternFiles(ctx.ProjectPath)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(manifest.Files) == 0 && len(patternMatched) == 0 {
 		log.Info("vault is empty")
-		return nil, nil
+		return []StatusRecord{}, nil
 	}
 
-	table := make(statusTable, 0, 32)
-
-	// iterate through files in the manifest
-	for _, filemeta := range manifest.Files {
-		var updated string
-		datafile, err := content.NewDatafile(ctx, filemeta.AbsPath)
-		if err != nil {
-			if !os.IsNotExist(err) {
-				log.LogError(err)
-			}
-			updated = "unavailable"
-		} else {
-			matches, err := datafile.MatchesCurrent(filemeta)
-			if err != nil {
-				updated = "unable to compare"
-			} else {
-				updated = strconv.FormatBool(!matches)
-			}
+	records := make([]StatusRecord, 0, 32)
+
+	// compare the files in the manifest in parallel
+	manifestRecords := make([]*StatusRecord, len(manifest.Files))
+	readErrs := make([]error, len(manifest.Files))
+	index := readIndex(ctx, opts)
+	forEachIndex(opts.jobs(), len(manifest.Files), func(i int) {
+		manifestRecords[i], readErrs[i] = fileStatus(ctx, index, manifest.Files[i], opts.Tags)
+	})
+	saveIndex(index, opts)
+
+	inManifest := make(map[string]bool, len(manifest.Files))
+	for i, filemeta := range manifest.Files {
+		inManifest[filemeta.AbsPath] = true
+		if readErrs[i] != nil {
+			log.LogError(readErrs[i])
 		}
+		if manifestRecords[i] != nil {
+			records = append(records, *manifestRecords[i])
+		}
+	}
+	patternMatched = util.Filter(patternMatched, func(path string) bool {
+		return !inManifest[path]
+	})
 
-		patternMatched = util.Filter(patternMatched, func(path string) bool {
-			return path != filemeta.AbsPath
-		})
-
-		table = append(table, []string{
-			filemeta.RelPath,
-			updated,
-			firstMatchedPattern(filemeta.RelPath, ctx.Config.Patterns),
-			filemeta.IdString(),
-		})
+	if len(opts.Tags) > 0 {
+		// files which are not in the vault yet do not have tags
+		patternMatched = nil
 	}
 
 	// iterate through any files matched, but that were not seen in the manifest
 	for _, notCommited := range patternMatched {
-		table = append(table, []string{
-			ctx.ProjRelPath(notCommited),
-			"new file",
-			firstMatchedPattern(ctx.ProjRelPath(notCommited), ctx.Config.Patterns),
-			"",
-		})
+		record := StatusRecord{
+			Path:    ctx.ProjRelPath(notCommited),
+			State:   StateNew,
+			Pattern: firstMatchedPattern(ctx.Config, ctx.ProjRelPath(notCommited), ctx.Config.Patterns),
+		}
+		if info, err := os.Lstat(notCommited); err == nil {
+			record.Perm = formatPerm(info.Mode().Perm())
+		}
+		records = append(records, record)
 	}
 
-	// sort the table and return it
-	sort.Sort(table)
-	return headers, table
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Path < records[j].Path
+	})
+	return records, nil
 }
 
-type statusTable [][]string
-
-func (s statusTable) Len() int {
-	return len(s)
-}
+// Returns the status of a file in the manifest, or nil if it does not have all of the tags.  If the plaintext
+// file exists but could not be read, the error is returned with the record.  The local index is used to avoid
+// decrypting the secret when its datafile and plaintext file have not changed.
+func fileStatus(ctx content.Context, index *content.Index, filemeta content.Filemeta, tags []string) (*StatusRecord, error) {
+	record := StatusRecord{
+		Path:    filemeta.RelPath,
+		Pattern: firstMatchedPattern(ctx.Config, filemeta.RelPath, ctx.Config.Patterns),
+		Id:      filemeta.IdString(),
+	}
+	var vaulted *content.Datafile
+	var vaultErr error
+	entry, cached := index.Get(filemeta)
+	cached = cached && entry.SecretCurrent(ctx, filemeta)
+	if !cached {
+		var datafile content.Datafile
+		datafile, vaultErr = content.ReadDatafile(ctx, filemeta)
+		if vaultErr == nil {
+			vaulted = &datafile
+			entry.Perm, entry.Meta = datafile.Perm(), datafile.Meta()
+		}
+	}
+	if vaultErr == nil {
+		record.Perm = formatPerm(os.FileMode(entry.Perm))
+	}
+	// without the key, the index only has the permissions since the metadata is encrypted
+	if vaultErr == nil && ctx.Key != nil {
+		record.Owner = entry.Meta.Owner
+		record.Tags = entry.Meta.Tags
+		record.Due, _ = dueCheck(entry.Meta, time.Now(), DefaultDueWithin)
+	}
+	if len(tags) > 0 && (vaultErr != nil || !entry.Meta.HasTags(tags)) {
+		return nil, nil
+	}
+	if cached && entry.Unchanged(ctx, filemeta) {
+		record.State = StateUnchanged
+		return &record, nil
+	}
 
-func (s statusTable) Less(i, j int) bool {
-	return s[i][0] < s[j][0]
+	var readErr error
+	datafile, err := content.NewDatafile(ctx, filemeta.AbsPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			readErr = err
+		} else if vaulted != nil {
+			index.Record(ctx, filemeta, nil, entry.Perm, entry.Meta)
+		}
+		record.State = StateUnavailable
+	} else if vaultErr != nil {
+		record.State = StateUnknown
+	} else if vaulted != nil && datafile.Equal(*vaulted) {
+		plaintext, _ := datafile.DecodeData()
+		index.Record(ctx, filemeta, plaintext, entry.Perm, entry.Meta)
+		record.State = StateUnchanged
+	} else if vaulted != nil {
+		record.State = StateUpdated
+	} else if matches, err := matchesCurrent(ctx, index, filemeta, datafile); err != nil {
+		record.State = StateUnknown
+	} else if matches {
+		record.State = StateUnchanged
+	} else {
+		record.State = StateUpdated
+	}
+	return &record, readErr
 }
 
-func (s statusTable) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
+func formatPerm(perm os.FileMode) string {
+	return fmt.Sprintf("%04o", uint32(perm))
 }
@@ -0,0 +1,155 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// The readable form of a datafile
+type DatafileText struct {
+	Decrypted bool   // false if the key for the vault is not available
+	Digest    string // sha256 of the encrypted datafile
+	Path      string // path and permissions are only set for files in .lockgit/data
+	Perm      os.FileMode
+	Data      []byte
+}
+
+// Decrypt a datafile without looking it up in the manifest, for use as a git textconv driver.  The
+// vault is found from the datafile's location, or from the working directory if the datafile is
+// elsewhere, such as a temporary file created by git.
+//
+// Files using the git filters are also supported.  Git smudges them before running textconv, so
+// they are usually plaintext already, and they are returned without a path or permissions.
+//
+// If a file cannot be decrypted, such as when the key is missing or it belongs to a profile with another
+// key, only its digest is returned.  An error would make git diff fail for the whole repository.
+func Textconv(opts Options, datafilePath string) (DatafileText, error) {
+	out := DatafileText{}
+	paths := []string{datafilePath}
+	pathsToAbs(opts.Wd, &paths)
+
+	ciphertext, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		return out, errors.Wrap(err, "unable to read datafile")
+	}
+	digest := sha256.Sum256(ciphertext)
+	out.Digest = hex.EncodeToString(digest[:])
+
+	store, err := opts.keyStore()
+	if err != nil {
+		return out, err
+	}
+	dir := opts.Wd
+	if _, err := content.FromPath(filepath.Dir(paths[0]), store); !content.IsVaultNotFoundError(err) {
+		dir = filepath.Dir(paths[0])
+	}
+	ctx, _, err := loadcm(dir, loadcmopts{ctxOnly: true, keyStore: store})
+	if err != nil {
+		return out, err
+	}
+	// git names temporary files after the original, so datafiles can also be recognized by their name
+	inDataDir := strings.HasPrefix(paths[0], ctx.DataPath+string(filepath.Separator)) || isDatafileName(filepath.Base(paths[0]))
+
+	if content.IsFiltered(ciphertext) {
+		if ctx.Key == nil {
+			return out, nil
+		}
+		datafile, err := content.DecryptFiltered(ctx, ciphertext)
+		if err == nil {
+			out.Data, err = datafile.DecodeData()
+		}
+		if err != nil {
+			log.Debugf("unable to decrypt %s: %s", ctx.RelPath(paths[0]), err)
+			return DatafileText{Digest: out.Digest}, nil
+		}
+		out.Decrypted = true
+		return out, nil
+	}
+
+	if ctx.Key == nil && !inDataDir {
+		out.Decrypted = true
+		out.Data = ciphertext
+		return out, nil
+	}
+	datafile, err := decodeAnyProfile(ctx, ciphertext)
+	if err != nil {
+		if !inDataDir {
+			// not a datafile, so it is plaintext from filter mode
+			out.Decrypted = true
+			out.Data = ciphertext
+			return out, nil
+		}
+		log.Debugf("unable to decrypt datafile %s: %s", ctx.RelPath(paths[0]), err)
+		return out, nil
+	}
+	out.Data, err = datafile.DecodeData()
+	if err != nil {
+		log.Debugf("unable to decode datafile %s: %s", ctx.RelPath(paths[0]), err)
+		return DatafileText{Digest: out.Digest}, nil
+	}
+	out.Decrypted = true
+	out.Path = datafile.Path()
+	out.Perm = os.FileMode(datafile.Perm())
+	return out, nil
+}
+
+// Decode a datafile with the key of the vault, or with the key of one of its profiles, since the datafiles of
+// every profile share .lockgit/data
+func decodeAnyProfile(ctx content.Context, ciphertext []byte) (content.Datafile, error) {
+	datafile, err := content.Datafile{}, errors.New("the key for the vault is not available")
+	// the ciphertext is decrypted in place, so each key is tried on a copy
+	if ctx.Key != nil {
+		datafile, err = content.DecodeDatafile(ctx, copyBytes(ciphertext))
+	}
+	for _, profile := range ctx.Config.Profiles {
+		if err == nil {
+			break
+		}
+		profileCtx, _, loadErr := loadcm(ctx.ProjectPath, loadcmopts{ctxOnly: true, profile: profile, keyStore: ctx.KeyStore})
+		if loadErr != nil || profileCtx.Key == nil {
+			continue
+		}
+		if profileDatafile, profileErr := content.DecodeDatafile(profileCtx, copyBytes(ciphertext)); profileErr == nil {
+			datafile, err = profileDatafile, nil
+		}
+	}
+	return datafile, err
+}
+
+func copyBytes(data []byte) []byte {
+	return append([]byte(nil), data...)
+}
+
+func isDatafileName(name string) bool {
+	id, err := base64.RawURLEncoding.DecodeString(name)
+	return err == nil && len(id) == 24
+}
@@ -0,0 +1,182 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+)
+
+// A vault recorded in the key store
+type VaultRecord struct {
+	Id     string `json:"id" yaml:"id"`
+	Path   string `json:"path" yaml:"path"`     // the last known location of the vault
+	HasKey bool   `json:"hasKey" yaml:"hasKey"` // true if the key is saved in the key store
+	Exists bool   `json:"exists" yaml:"exists"` // true if the vault with this id is still at the path
+}
+
+// Returns the vaults in the key store, sorted by path
+func ListVaults(opts Options) ([]VaultRecord, error) {
+	store, err := opts.keyStore()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]VaultRecord, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Profile != "" {
+			continue
+		}
+		record := VaultRecord{
+			Id:     entry.Id,
+			Path:   entry.Path,
+			HasKey: entry.Key != "",
+		}
+		if record.Path != "" {
+			// another vault may have been created where this one used to be
+			record.Exists = vaultIdAt(record.Path) == record.Id
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Path == records[j].Path {
+			return records[i].Id < records[j].Id
+		}
+		return records[i].Path < records[j].Path
+	})
+	return records, nil
+}
+
+// Remove the vaults which no longer exist from the key store.  Vaults with a key are only removed if
+// withKeys is set, which requires --force because the keys cannot be recovered.  Returns the vaults
+// which were removed.
+func PruneVaults(opts Options, withKeys bool) ([]VaultRecord, error) {
+	if withKeys && !opts.Force {
+		return nil, fmt.Errorf("this operation will irrevocably delete the keys for vaults which no longer exist and requires --force to proceed")
+	}
+
+	store, err := opts.keyStore()
+	if err != nil {
+		return nil, err
+	}
+	records, err := ListVaults(opts)
+	if err != nil {
+		return nil, err
+	}
+	pruned := make([]VaultRecord, 0)
+	for _, record := range records {
+		if record.Exists {
+			continue
+		}
+		if record.HasKey && !withKeys {
+			log.Infof("keeping %s at %s because its key is saved - use --keys to remove it", record.Id, record.Path)
+			continue
+		}
+		pruned = append(pruned, record)
+	}
+
+	if opts.DryRun {
+		return pruned, nil
+	}
+	for _, record := range pruned {
+		if err := store.Delete(record.Id, ""); err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+// Record that a vault has moved to another directory, such as when the directory was renamed while the vault
+// was not in use.  The vault at the new path must have the same id.
+func RelocateVault(opts Options, id string, path string) (VaultRecord, error) {
+	store, err := opts.keyStore()
+	if err != nil {
+		return VaultRecord{}, err
+	}
+	records, err := ListVaults(opts)
+	if err != nil {
+		return VaultRecord{}, err
+	}
+	paths := []string{path}
+	pathsToAbs(opts.Wd, &paths)
+	for _, record := range records {
+		if record.Id != id {
+			continue
+		}
+		if found := vaultIdAt(paths[0]); found == "" {
+			return record, fmt.Errorf("there is no vault in %s", paths[0])
+		} else if found != id {
+			return record, fmt.Errorf("the vault in %s has id %s, not %s", paths[0], found, id)
+		}
+		record.Path, record.Exists = paths[0], true
+		if opts.DryRun {
+			return record, nil
+		}
+		entry, err := store.Get(id, "")
+		if err != nil {
+			return record, err
+		}
+		entry.Path = paths[0]
+		return record, store.Set(entry)
+	}
+	return VaultRecord{}, fmt.Errorf("no vault with id %s in %s", id, store)
+}
+
+// Remove a single vault from the key store.  If its key is saved, --force is required.
+func ForgetVault(opts Options, id string) (VaultRecord, error) {
+	store, err := opts.keyStore()
+	if err != nil {
+		return VaultRecord{}, err
+	}
+	records, err := ListVaults(opts)
+	if err != nil {
+		return VaultRecord{}, err
+	}
+	for _, record := range records {
+		if record.Id != id {
+			continue
+		}
+		if record.HasKey && !opts.Force {
+			return record, fmt.Errorf("this operation will irrevocably delete the key for %s and requires --force to proceed", id)
+		}
+		if opts.DryRun {
+			return record, nil
+		}
+		return record, store.Delete(id, "")
+	}
+	return VaultRecord{}, fmt.Errorf("no vault with id %s in %s", id, store)
+}
+
+// Returns the id of the vault in a directory, or "" if there is no vault in it
+func vaultIdAt(path string) string {
+	config, err := content.ReadConfig(content.Context{ConfigPath: filepath.Join(path, ".lockgit", "lgconfig")})
+	if err != nil {
+		return ""
+	}
+	return config.Id
+}
@@ -0,0 +1,204 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/jswidler/lockgit/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// Watch the secrets in the vault and commit them whenever they change, until stop is closed.  The directories
+// of the files in the manifest and the directories covered by the saved glob patterns are watched.  Events are
+// debounced, so a burst of writes results in a single commit.  After each commit, onCommit is called with the
+// result of Commit.  If ready is not nil, it is closed once the files are being watched.
+//
+// Changes to the vault made by other commands, such as adding a glob pattern, are picked up while watching.
+func Watch(opts Options, debounce time.Duration, stop <-chan struct{}, ready chan<- struct{}, onCommit func([]FileChange, error)) error {
+	ctx, manifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "unable to start watching")
+	}
+	defer watcher.Close()
+
+	// the config and manifest are watched to pick up changes made by other commands
+	for _, dir := range []string{filepath.Dir(ctx.ConfigPath), filepath.Dir(ctx.ManifestPath)} {
+		if err := watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "unable to watch %s", ctx.RelPath(dir))
+		}
+	}
+
+	watched := make(map[string]bool)
+	refresh := func() {
+		newCtx, newManifest, err := loadcm(opts.Wd, loadcmopts{keyRequired: true, profile: opts.Profile, keyStore: opts.KeyStore})
+		if err != nil {
+			log.LogError(err)
+			return
+		}
+		ctx, manifest = newCtx, newManifest
+		for _, dir := range watchDirs(ctx, manifest) {
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				log.LogError(errors.Wrapf(err, "unable to watch %s", ctx.RelPath(dir)))
+				continue
+			}
+			watched[dir] = true
+			log.Verbose("watching " + ctx.RelPath(dir))
+		}
+	}
+	refresh()
+	if ready != nil {
+		close(ready)
+	}
+
+	// the config and manifest are reloaded once they have been written, which is debounced like commits
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	commit := false
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-watcher.Errors:
+			log.LogError(errors.Wrap(err, "error watching files"))
+		case event := <-watcher.Events:
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				// the watch is dropped with the directory, so it is watched again if it is recreated
+				forgetWatched(watched, event.Name)
+			}
+			if event.Name == ctx.ConfigPath || event.Name == ctx.ManifestPath {
+				timer.Reset(debounce)
+			} else if watchTriggers(ctx, manifest, event) {
+				commit = true
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			if commit {
+				commit = false
+				onCommit(Commit(opts))
+			}
+			refresh()
+		}
+	}
+}
+
+// Remove a directory which no longer exists, and the directories inside it, from the watched directories
+func forgetWatched(watched map[string]bool, dir string) {
+	for watchedDir := range watched {
+		if watchedDir == dir || strings.HasPrefix(watchedDir, dir+string(filepath.Separator)) {
+			delete(watched, watchedDir)
+		}
+	}
+}
+
+// True if an event may change the result of Commit
+func watchTriggers(ctx content.Context, manifest content.Manifest, event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if strings.HasPrefix(event.Name, ctx.LockgitPath+string(filepath.Separator)) || event.Name == ctx.LockgitPath {
+		return false
+	}
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+		// new directories are watched after the next commit, and may already contain secrets
+		return event.Op&fsnotify.Create != 0
+	}
+	relPath := ctx.ProjRelPath(event.Name)
+	return manifest.Find(relPath) >= 0 || firstMatchedPattern(ctx.Config, relPath, ctx.Config.Patterns) != ""
+}
+
+// Returns the directories to watch for changes to the files in the manifest and the saved glob patterns.
+// Directories which do not exist are replaced by their closest parent that does.
+func watchDirs(ctx content.Context, manifest content.Manifest) []string {
+	dirs := make([]string, 0, 16)
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		dir = existingParent(ctx, dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, filemeta := range manifest.Files {
+		add(filepath.Dir(filemeta.AbsPath))
+	}
+	for _, pattern := range ctx.Config.Patterns {
+		if util.IsNegated(pattern) {
+			continue
+		}
+		base, recursive := patternBase(ctx.Config.PatternGlob(pattern))
+		// with the .gitignore syntax, a pattern matching a directory matches everything inside it
+		recursive = recursive || ctx.Config.GitignoreSyntax
+		absBase := filepath.Join(ctx.ProjectPath, base)
+		add(absBase)
+		if !recursive {
+			continue
+		}
+		_ = filepath.Walk(absBase, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			if name := info.Name(); name == ".git" || name == ".lockgit" {
+				return filepath.SkipDir
+			}
+			add(path)
+			return nil
+		})
+	}
+	return dirs
+}
+
+// Split a glob pattern into the directory before the first special term, and whether the rest of the pattern
+// can match files in subdirectories of it
+func patternBase(pattern string) (string, bool) {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	for i, part := range parts {
+		if strings.ContainsAny(part, "*?[{\\") {
+			return filepath.Join(parts[:i]...), i < len(parts)-1 || strings.Contains(part, "**")
+		}
+	}
+	return filepath.Join(parts[:len(parts)-1]...), false
+}
+
+func existingParent(ctx content.Context, dir string) string {
+	for dir != ctx.ProjectPath && dir != filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
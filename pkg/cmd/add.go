@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"github.com/jswidler/lockgit/pkg/app"
 	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/spf13/cobra"
 )
@@ -44,11 +43,15 @@ Files and glob patterns added to the vault can be removed with the rm command.
   lockgit add credentials
 
   Add all .key files in the src directory and its subfolders:
-  lockgit add 'src/**/*.key'`,
+  lockgit add 'src/**/*.key'
+
+  Add everything in the config directory except example files:
+  lockgit add config --exclude 'config/**/*.example'`,
 
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := app.AddToVault(cliFlags(), args)
+		changes, err := openVault().Add(args...)
+		renderChanges(changes)
 		log.FatalExit(err)
 	},
 }
@@ -57,4 +60,6 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 
 	addForceFlag(addCmd, "allow overwriting of existing files in the vault")
+	addExcludeFlag(addCmd, "save a negated glob pattern which excludes matching files from the vault")
+	addCmd.Flags().StringVar(&rotateEvery, "rotate-every", "", "remind you to rotate the secrets this often, such as 90d")
 }
@@ -0,0 +1,91 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var description, owner, expires string
+var addTags, removeTags []string
+
+// annotateCmd represents the annotate command
+var annotateCmd = &cobra.Command{
+	Use:   "annotate [file|glob] ...",
+	Short: "Set the description, owner and tags of secrets",
+	Long: `Set the description, owner and tags of secrets in the vault.  The metadata is encrypted along with each secret
+and shown by ls --long and status.  Secrets with an expiry date or a rotation period are reported by due once they
+need attention.
+
+If files or glob patterns are given, only the matching secrets are changed, otherwise every secret is.`,
+
+	Example: `  Record who owns a key and what it is for:
+  lockgit annotate config/legacy.key --owner ops@example.com --description "signs the nightly export"
+
+  Tag the production secrets, then restore only those:
+  lockgit annotate 'prod/**' --add-tag prod
+  lockgit open --tag prod`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		annotation := app.Annotation{AddTags: addTags, RemoveTags: removeTags}
+		if cmd.Flags().Changed("description") {
+			annotation.Description = &description
+		}
+		if cmd.Flags().Changed("owner") {
+			annotation.Owner = &owner
+		}
+		if cmd.Flags().Changed("rotate-every") {
+			annotation.RotateEvery = &rotateEvery
+		}
+		if cmd.Flags().Changed("expires") {
+			date := time.Time{}
+			if expires != "" {
+				var err error
+				date, err = time.ParseInLocation("2006-01-02", expires, time.Local)
+				log.FatalExit(errors.Wrap(err, "invalid --expires date: use YYYY-MM-DD"))
+			}
+			annotation.Expires = &date
+		}
+		if reflect.DeepEqual(annotation, app.Annotation{}) {
+			log.FatalExit(errors.New("nothing to change: use --description, --owner, --add-tag, --rm-tag, --rotate-every or --expires"))
+		}
+		changes, err := app.Annotate(cliFlags(), args, annotation)
+		renderChanges(changes)
+		log.FatalExit(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(annotateCmd)
+	annotateCmd.Flags().StringVarP(&description, "description", "d", "", "what the secret is and what uses it")
+	annotateCmd.Flags().StringVar(&owner, "owner", "", "who is responsible for the secret")
+	annotateCmd.Flags().StringArrayVar(&addTags, "add-tag", nil, "add a tag, which can be given more than once")
+	annotateCmd.Flags().StringArrayVar(&removeTags, "rm-tag", nil, "remove a tag, which can be given more than once")
+	annotateCmd.Flags().StringVar(&rotateEvery, "rotate-every", "", "remind you to rotate the secrets this often, such as 90d, or \"\" to stop")
+	annotateCmd.Flags().StringVar(&expires, "expires", "", "the date the secrets expire, as YYYY-MM-DD, or \"\" to remove it")
+	addTagFlag(annotateCmd)
+	addExcludeFlag(annotateCmd, "do not change secrets matching the glob pattern")
+}
@@ -0,0 +1,54 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// catCmd represents the cat command
+var catCmd = &cobra.Command{
+	Use:   "cat <file>",
+	Short: "Print the decrypted contents of a secret",
+	Long: `Print the decrypted contents of a secret to stdout without writing it to disk.
+
+With --rev, the secret is read from a git revision without checking it out.`,
+
+	Example: `  Show a secret as it was two commits ago:
+  lockgit cat --rev HEAD~2 config/creds.json`,
+
+	Args: cobraNamedPositionalArgs("file"),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := app.Cat(cliFlags(), args[0])
+		log.FatalExit(err)
+		_, err = os.Stdout.Write(data)
+		log.FatalExit(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(catCmd)
+	addRevFlag(catCmd)
+}
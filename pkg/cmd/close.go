@@ -21,23 +21,32 @@
 package cmd
 
 import (
-	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/spf13/cobra"
 )
 
 // closeCmd represents the close command
 var closeCmd = &cobra.Command{
-	Use:     "close",
+	Use:     "close [file|glob] ...",
 	Short:   "Delete plaintext secrets",
 	Aliases: []string{"clean"},
+	Long: `Delete plaintext secrets which are saved in the vault.
+
+If files or glob patterns are given, only the matching secrets are deleted.`,
+
+	Example: `  Delete every plaintext secret except the nginx certificates:
+  lockgit close --exclude 'nginx/**'`,
 
-	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		app.CloseVault(cliFlags())
+		changes, err := openVault().CloseFiles(args...)
+		renderChanges(changes)
+		log.FatalExit(err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(closeCmd)
 	addForceFlag(closeCmd, "delete files even if they have unsaved changes")
+	addTagFlag(closeCmd)
+	addExcludeFlag(closeCmd, "do not delete files matching the glob pattern")
 }
@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"github.com/jswidler/lockgit/pkg/app"
 	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/spf13/cobra"
 )
@@ -33,7 +32,8 @@ var commitCmd = &cobra.Command{
 
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := app.Commit(cliFlags())
+		changes, err := openVault().Commit()
+		renderChanges(changes)
 		log.FatalExit(err)
 	},
 }
@@ -49,7 +49,8 @@ var completionCmd = &cobra.Command{
 var output string
 
 func addOutputFlag(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&output, "output", "o", "", "output to a file")
+	// not --output, which is the output format of every command
+	cmd.Flags().StringVar(&output, "out-file", "", "output to a file")
 }
 
 var zsh bool
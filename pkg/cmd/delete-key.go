@@ -36,8 +36,9 @@ You will not be able to recover the key after running this command.  It requires
 
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := app.UnsetKey(cliFlags())
+		record, err := app.UnsetKey(cliFlags())
 		log.FatalExit(err)
+		renderKeyRecord(record)
 	},
 }
 
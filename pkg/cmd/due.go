@@ -0,0 +1,76 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"os"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/jswidler/lockgit/pkg/util"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var within string
+
+// dueCmd represents the due command
+var dueCmd = &cobra.Command{
+	Use:   "due",
+	Short: "List secrets which have expired or should be rotated",
+	Long: `List the secrets which have expired or are due to be rotated, or will be soon.  Set an expiry date with
+lockgit annotate --expires, and a rotation period with lockgit add --rotate-every or lockgit annotate --rotate-every.
+The rotation period counts from the last time the secret was committed to the vault.
+
+Exits with 2 if any secrets are listed, so it can be run in CI.`,
+
+	Example: `  Fail if any secret expires or should be rotated in the next two weeks:
+  lockgit due --within 14d`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		duration, err := util.ParseDuration(within)
+		log.FatalExit(err)
+		records, err := app.Due(cliFlags(), duration)
+		render(records, func() { dueTable(records) })
+		log.FatalExit(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dueCmd)
+	dueCmd.Flags().StringVar(&within, "within", "30d", "also list secrets which will need attention within this long")
+	addTagFlag(dueCmd)
+}
+
+func dueTable(records []app.DueRecord) {
+	if len(records) == 0 {
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+
+	table.SetHeader([]string{"file", "reason", "date", "owner"})
+	for _, r := range records {
+		table.Append([]string{r.Path, string(r.Reason), r.Date.Local().Format("2006-01-02"), r.Owner})
+	}
+
+	table.Render()
+}
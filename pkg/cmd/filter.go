@@ -0,0 +1,94 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// filterCmd represents the filter command
+var filterCmd = &cobra.Command{
+	Use:   "filter",
+	Short: "Encrypt secrets transparently with git filters",
+	Long: `Encrypt secrets transparently with git clean and smudge filters.
+
+In filter mode, secrets are committed to git at their normal paths.  Git encrypts them with the clean filter when they
+are added and decrypts them with the smudge filter when they are checked out.  Anyone without the key sees only the
+encrypted files.  Use filter install to set up the filters for the saved glob patterns.`,
+}
+
+// filterInstallCmd represents the filter install command
+var filterInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Configure git filters for the saved glob patterns",
+	Long: `Configure git to encrypt files matching the saved glob patterns with the lockgit filters.
+
+The patterns are added to .gitattributes and the filters are registered in the local git config.  Files using the
+filters must not be ignored by git, so use --no-update-gitignore when adding secrets in filter mode.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		warnings, err := app.InstallFilter(cliFlags())
+		log.FatalExit(err)
+		render(warnings, func() {
+			for _, warning := range warnings {
+				log.Info(warning)
+			}
+		})
+	},
+}
+
+// filterCleanCmd represents the filter clean command
+var filterCleanCmd = &cobra.Command{
+	Use:    "clean <file>",
+	Hidden: true,
+	Short:  "Encrypt a file from stdin as a git clean filter",
+
+	Args: cobraNamedPositionalArgs("file"),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := app.FilterClean(cliFlags(), args[0], os.Stdin, os.Stdout)
+		log.FatalExit(err)
+	},
+}
+
+// filterSmudgeCmd represents the filter smudge command
+var filterSmudgeCmd = &cobra.Command{
+	Use:    "smudge <file>",
+	Hidden: true,
+	Short:  "Decrypt a file from stdin as a git smudge filter",
+
+	Args: cobraNamedPositionalArgs("file"),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := app.FilterSmudge(cliFlags(), args[0], os.Stdin, os.Stdout)
+		log.FatalExit(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(filterCmd)
+	filterCmd.AddCommand(filterInstallCmd)
+	filterCmd.AddCommand(filterCleanCmd)
+	filterCmd.AddCommand(filterSmudgeCmd)
+}
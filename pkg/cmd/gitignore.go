@@ -0,0 +1,67 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// gitignoreCmd represents the gitignore command
+var gitignoreCmd = &cobra.Command{
+	Use:   "gitignore",
+	Short: "Check that git ignores the plaintext secrets",
+	Long: `lockgit adds the files and patterns in the vault to a block at the end of the .gitignore file in the project root,
+between the lines "# BEGIN lockgit" and "# END lockgit".  rm removes them from the block again.  Lines outside of
+the block are never changed.`,
+}
+
+// gitignoreCheckCmd represents the gitignore check command
+var gitignoreCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Report secrets which git would not ignore",
+	Long: `Report every file in the vault, or matching one of its glob patterns, which git would not ignore.  git decides,
+so nested .gitignore files, .git/info/exclude and negated patterns are all taken into account.
+
+Exits with 2 if any secret is not ignored.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, err := app.CheckGitignore(cliFlags())
+		if paths == nil {
+			log.FatalExit(err)
+		}
+		render(paths, func() {
+			for _, path := range paths {
+				fmt.Fprintf(os.Stdout, "%s is not ignored by git\n", path)
+			}
+		})
+		log.FatalExit(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gitignoreCmd)
+	gitignoreCmd.AddCommand(gitignoreCheckCmd)
+}
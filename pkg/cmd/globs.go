@@ -24,6 +24,7 @@ import (
 	"fmt"
 
 	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/spf13/cobra"
 )
 
@@ -37,10 +38,13 @@ var globsCmd = &cobra.Command{
 
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		files := app.LsGlobs(cliFlags())
-		for _, f := range files {
-			fmt.Println(f)
-		}
+		globs, err := app.LsGlobs(cliFlags())
+		log.FatalExit(err)
+		render(globs, func() {
+			for _, g := range globs {
+				fmt.Println(g)
+			}
+		})
 	},
 }
 
@@ -48,7 +52,18 @@ func init() {
 	rootCmd.AddCommand(globsCmd)
 }
 
-const globHelp = `Glob patterns supported are like those found in a .gitignore file.  The following special terms are supported:
+const globHelp = `Glob patterns are matched like the lines of a .gitignore file in the working directory, and are saved relative to
+the root of the project:
+
+  - A pattern without a slash, such as *.pem, matches a file or directory with that name at any depth
+  - A pattern with a slash at the beginning or in the middle, such as config/*.pem, is anchored to the directory
+  - A pattern ending with a slash, such as tls/, only matches directories
+  - A pattern matching a directory matches every file inside of it
+
+Vaults created by older versions of lockgit match patterns against the whole path from the root of the project
+instead, and keep doing so unless "GitignoreSyntax": true is set in .lockgit/lgconfig.
+
+The following special terms are supported:
 
   *           matches any sequence of non-path-separators
   **          matches any sequence of characters, including path separator
@@ -62,4 +77,8 @@ Character classes support the following:
   [a-z]       matches any single character in the range
   [^class]    matches any single character which does not match the class
 
+A saved pattern starting with ! is negated: files it matches are excluded from the vault, even if an earlier pattern
+matches them.  Patterns are applied in the order they were saved, so a later pattern can include the files again.
+Negated patterns are saved with the --exclude flag of the add command, and removed with rm.
+
 Note: It is a good idea to surround glob patterns with quotes to prevent shell wildcard expansion.`
@@ -0,0 +1,85 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// hookCmd represents the hook command
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Manage the git pre-commit hook",
+}
+
+// hookInstallCmd represents the hook install command
+var hookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a git pre-commit hook which checks the vault",
+	Long: `Install a git pre-commit hook which runs lockgit hook pre-commit before each git commit.  The hook changes to
+the vault's directory first, so the vault can be in a subdirectory of the repository.
+
+The lockgit binary must be in the PATH when git runs.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := app.InstallHook(cliFlags())
+		log.FatalExit(err)
+	},
+}
+
+// hookPreCommitCmd represents the hook pre-commit command
+var hookPreCommitCmd = &cobra.Command{
+	Use:   "pre-commit",
+	Short: "Check for plaintext secrets and uncommitted vault changes",
+	Long: `Check for plaintext secrets and uncommitted vault changes.  Fails if any file in the vault, or any file matching a
+saved glob pattern, is staged in git, or if status shows secrets which have changed but have not been committed to the
+vault.  Every profile of the vault is checked.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		problems, err := app.PreCommitCheck(cliFlags())
+		log.FatalExit(err)
+		render(problems, func() {
+			if len(problems) > 0 {
+				for _, problem := range problems {
+					fmt.Fprintln(os.Stderr, problem)
+				}
+				fmt.Fprintln(os.Stderr, "lockgit: commit blocked")
+			}
+		})
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(hookInstallCmd)
+	hookCmd.AddCommand(hookPreCommitCmd)
+	addForceFlag(hookInstallCmd, "replace an existing pre-commit hook")
+}
@@ -33,8 +33,9 @@ var initCmd = &cobra.Command{
 
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := app.InitVault(cliFlags())
+		record, err := app.InitVault(cliFlags())
 		log.FatalExit(err)
+		renderKeyRecord(record)
 	},
 }
 
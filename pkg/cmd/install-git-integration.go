@@ -0,0 +1,49 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// installGitIntegrationCmd represents the install-git-integration command
+var installGitIntegrationCmd = &cobra.Command{
+	Use:   "install-git-integration",
+	Short: "Register lockgit's drivers with git",
+	Long: `Register lockgit's drivers in .gitattributes and the local git config.
+
+The merge driver merges changes to .lockgit/manifest one secret at a time, so that branches which change different
+secrets do not conflict.  The diff driver decrypts files in .lockgit/data, so git diff and git log -p show the changes
+to secrets when the key is present.  The git config is not shared by clones, so each clone of the repository needs to run this
+command.  The lockgit binary must be in the PATH when git runs.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := app.InstallGitIntegration(cliFlags())
+		log.FatalExit(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(installGitIntegrationCmd)
+}
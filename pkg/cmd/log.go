@@ -0,0 +1,74 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// logCmd represents the log command
+var logCmd = &cobra.Command{
+	Use:   "log [file|glob] ...",
+	Short: "Show the history of secrets in git",
+	Long: `Show when secrets were added, changed or removed by walking the git history of the manifest.
+
+If files or glob patterns are given, only the history of matching secrets is shown.  With --patch, the decrypted
+contents of each version are compared, which requires the key to the vault.`,
+
+	Example: `  Show the history of every secret in the vault:
+  lockgit log
+
+  Show the changes made to a single secret:
+  lockgit log -p config/creds.json`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		history, err := app.History(cliFlags(), args, patch)
+		log.FatalExit(err)
+		render(history, func() {
+			for i, entry := range history {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("commit %s\n", entry.Commit)
+				fmt.Printf("Author: %s <%s>\n", entry.Author, entry.Email)
+				fmt.Printf("Date:   %s\n", entry.Date.Format("Mon Jan 2 15:04:05 2006 -0700"))
+				fmt.Printf("\n    %s\n\n", entry.Subject)
+				for _, change := range entry.Changes {
+					fmt.Printf("%-8s %s\n", change.Type, change.Path)
+					if change.Diff != "" {
+						fmt.Print(change.Diff)
+					}
+				}
+			}
+		})
+	},
+}
+
+var patch bool
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	logCmd.Flags().BoolVarP(&patch, "patch", "p", false, "show the changes to the decrypted contents of each secret")
+}
@@ -22,8 +22,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -32,15 +36,49 @@ var lsCmd = &cobra.Command{
 	Use:   "ls",
 	Short: "List the files in the lockgit vault",
 
+	Long: `List the files in the lockgit vault.
+
+With --long, the owner, tags, creation date and description of each secret are shown too.  This requires the key,
+as does filtering by --tag.`,
+
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		files := app.Ls(cliFlags())
-		for _, f := range files {
-			fmt.Println(f)
+		if long {
+			records, err := app.LsLong(cliFlags())
+			render(records, func() { secretsTable(records) })
+			log.FatalExit(err)
+			return
 		}
+		files, err := app.Ls(cliFlags())
+		log.FatalExit(err)
+		render(files, func() {
+			for _, f := range files {
+				fmt.Println(f)
+			}
+		})
 	},
 }
 
+var long bool
+
 func init() {
 	rootCmd.AddCommand(lsCmd)
+	lsCmd.Flags().BoolVarP(&long, "long", "l", false, "show the metadata of each secret")
+	addTagFlag(lsCmd)
+}
+
+func secretsTable(records []app.SecretRecord) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+
+	table.SetHeader([]string{"file", "owner", "tags", "created", "description"})
+	for _, r := range records {
+		created := ""
+		if !r.Created.IsZero() {
+			created = r.Created.Local().Format("2006-01-02")
+		}
+		table.Append([]string{r.Path, r.Owner, strings.Join(r.Tags, ","), created, r.Description})
+	}
+
+	table.Render()
 }
@@ -0,0 +1,68 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// mergeDriverCmd represents the merge-driver command
+var mergeDriverCmd = &cobra.Command{
+	Use:    "merge-driver <base> <current> <other> [path]",
+	Hidden: true,
+	Short:  "Merge .lockgit/manifest as a git merge driver",
+	Long: `Merge .lockgit/manifest as a git merge driver.  Use install-git-integration to register it with git.
+
+Each secret is merged separately, so branches which change different secrets merge cleanly.  If both branches
+changed the same secret, the merge fails and the current branch's version is kept in the manifest.  When the key is
+present, the decrypted versions of the secret are written to .git/lockgit/merge for comparison.  To resolve the
+conflict, write the correct version of the secret, run lockgit commit and then add .lockgit to git.`,
+
+	Args: cobra.RangeArgs(3, 4),
+	Run: func(cmd *cobra.Command, args []string) {
+		mergedPath := ""
+		if len(args) == 4 {
+			mergedPath = args[3]
+		}
+		conflicts, err := app.MergeManifests(cliFlags(), args[0], args[1], args[2], mergedPath)
+		log.FatalExit(err)
+		if len(conflicts) == 0 {
+			return
+		}
+		for _, conflict := range conflicts {
+			fmt.Fprintf(os.Stderr, "CONFLICT (secret): %s was changed in both branches\n", conflict.Path)
+			if len(conflict.Versions) > 0 {
+				fmt.Fprintf(os.Stderr, "  decrypted versions: %s\n", strings.Join(conflict.Versions, ", "))
+			}
+		}
+		os.Exit(1)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mergeDriverCmd)
+}
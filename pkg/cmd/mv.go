@@ -0,0 +1,51 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// mvCmd represents the mv command
+var mvCmd = &cobra.Command{
+	Use:   "mv <source> <destination>",
+	Short: "Move a secret or a directory of secrets to a new path",
+	Long: `Move a secret, or every secret inside a directory, to a new path in the vault.  The plaintext files are moved too
+if they are open, and the entries lockgit added to .gitignore are updated to the new paths.  If the destination is a
+directory which exists, the source is moved inside of it.
+
+Only the secrets are moved, so other files in a directory stay where they are.`,
+
+	Aliases: []string{"move"},
+
+	Args: cobraNamedPositionalArgs("source", "destination"),
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := openVault().Move(args[0], args[1])
+		renderChanges(changes)
+		log.FatalExit(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+	addForceFlag(mvCmd, "overwrite plaintext files at the destination")
+}
@@ -21,22 +21,39 @@
 package cmd
 
 import (
-	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/spf13/cobra"
 )
 
 // openCmd represents the open command
 var openCmd = &cobra.Command{
-	Use:   "open",
+	Use:   "open [file|glob] ...",
 	Short: "Decrypt and restore secrets in the vault",
+	Long: `Decrypt and restore secrets in the vault.
+
+If files or glob patterns are given, only the matching secrets are restored.  With --rev, the secrets are read from a
+git revision without checking it out, which can be used to restore a secret to an older version.`,
+
+	Example: `  Restore every secret in the vault:
+  lockgit open
+
+  Restore only the nginx certificates:
+  lockgit open 'nginx/**/*.pem'
+
+  Restore a secret as it was before the last commit:
+  lockgit open --force --rev HEAD~1 config/creds.json`,
 
-	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		app.OpenVault(cliFlags())
+		changes, err := openVault().OpenFiles(args...)
+		renderChanges(changes)
+		log.FatalExit(err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(openCmd)
 	addForceFlag(openCmd, "overwrite files that exist")
+	addRevFlag(openCmd)
+	addTagFlag(openCmd)
+	addExcludeFlag(openCmd, "do not restore files matching the glob pattern")
 }
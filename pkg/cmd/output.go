@@ -0,0 +1,125 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	outputTable = "table"
+	outputJson  = "json"
+	outputYaml  = "yaml"
+)
+
+var outputFormat string
+var quiet, verbose, debug bool
+
+// The environment variable which sets the log level, unless it is set by a flag
+const logEnv = "LOCKGIT_LOG"
+
+func addOutputFormatFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "output format: table, json or yaml")
+}
+
+// Check the output format, and switch logging to the selected format
+func setupOutput() error {
+	switch outputFormat {
+	case outputTable:
+		log.SetFormat(log.FormatText)
+	case outputJson:
+		log.SetFormat(log.FormatJson)
+	case outputYaml:
+		log.SetFormat(log.FormatYaml)
+	default:
+		return errors.Errorf("unknown output format '%s': expected table, json or yaml", outputFormat)
+	}
+	return nil
+}
+
+// Set the log level from the flags, or from LOCKGIT_LOG if no flag is given
+func setupLogging() error {
+	level := log.LevelNormal
+	if env := os.Getenv(logEnv); env != "" {
+		var err error
+		level, err = log.ParseLevel(env)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", logEnv)
+		}
+	}
+	switch {
+	case debug:
+		level = log.LevelDebug
+	case verbose:
+		level = log.LevelVerbose
+	case quiet:
+		level = log.LevelQuiet
+	}
+	log.SetLevel(level)
+	log.Debugf("log level is %s", level)
+	return nil
+}
+
+// Print the result of a command in the selected output format.  The table function prints the human readable form.
+func render(result interface{}, table func()) {
+	switch outputFormat {
+	case outputJson:
+		out, err := json.MarshalIndent(result, "", "  ")
+		log.FatalExit(err)
+		fmt.Fprintf(os.Stdout, "%s\n", out)
+	case outputYaml:
+		out, err := yaml.Marshal(result)
+		log.FatalExit(err)
+		fmt.Fprint(os.Stdout, string(out))
+	default:
+		table()
+	}
+}
+
+// Print the vault whose key was changed by a command.  In table format, the change has already been logged.
+func renderKeyRecord(record app.KeyRecord) {
+	render(record, func() {})
+}
+
+// Print the files changed by a command.  In table format, the changes have already been logged as they were made.
+func renderChanges(changes []app.FileChange) {
+	render(changes, func() {
+		if dryRun {
+			log.Info("dry run - no files were changed")
+		}
+	})
+}
+
+// Returns the verb for a change, or the verb for what would be changed in a dry run
+func dryRunVerb(done, wouldDo string) string {
+	if dryRun {
+		return wouldDo
+	}
+	return done
+}
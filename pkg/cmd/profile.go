@@ -0,0 +1,93 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage the profiles of the vault",
+	Long: `Manage the profiles of the vault.
+
+A profile, such as dev, staging or prod, has its own key, secrets and glob patterns.  Use --profile with any command to
+work with a profile instead of the rest of the vault.  Someone who only has the key for one profile can open it and
+check its status without the keys for the others.`,
+
+	Example: `  Add a prod profile and add a secret to it:
+  lockgit profile add prod
+  lockgit --profile prod add config/prod.env
+
+  Decrypt only the prod secrets:
+  lockgit --profile prod open`,
+}
+
+// profileAddCmd represents the profile add command
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a profile with a new key",
+
+	Args: cobraNamedPositionalArgs("name"),
+	Run: func(cmd *cobra.Command, args []string) {
+		record, err := app.AddProfile(cliFlags(), args[0])
+		log.FatalExit(err)
+		renderKeyRecord(record)
+	},
+}
+
+// profileListCmd represents the profile list command
+var profileListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the profiles of the vault",
+	Aliases: []string{"ls"},
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles, err := app.ListProfiles(cliFlags())
+		log.FatalExit(err)
+		render(profiles, func() { profilesTable(profiles) })
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileAddCmd)
+	profileCmd.AddCommand(profileListCmd)
+}
+
+func profilesTable(profiles []app.ProfileRecord) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+
+	table.SetHeader([]string{"name", "key", "files"})
+	for _, p := range profiles {
+		table.Append([]string{p.Name, strconv.FormatBool(p.HasKey), strconv.Itoa(p.Files)})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,76 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"os"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var register bool
+var renderFile string
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render <template>",
+	Short: "Render a config file from a template using secrets in the vault",
+	Long: `Render a Go text/template using the decrypted contents of secrets in the vault.  The output is printed
+to stdout, or written to a file which only the owner can read with --out-file.
+
+Paths in the template are relative to the project root.  These functions are available:
+
+  {{ secret "config/creds.json" }}               the contents of a secret
+  {{ json "config/creds.json" "db.password" }}   a value from a JSON secret, array elements are selected by index
+  {{ kv "API_TOKEN" }}                           the value of a KEY=VALUE line in any secret, such as a .env file
+  {{ kv "API_TOKEN" "config/.env" }}             the value of a KEY=VALUE line in the given secrets
+
+With --register, the rendered file is added to .gitignore and deleted by close along with the secrets.`,
+
+	Example: `  Render the application config and delete it when the vault is closed:
+  lockgit render config/app.yml.tmpl --out-file config/app.yml --register`,
+
+	Args: cobraNamedPositionalArgs("template"),
+	Run: func(cmd *cobra.Command, args []string) {
+		if renderFile == "" {
+			if register {
+				log.FatalExit(errors.New("--register requires --out-file"))
+			}
+			data, err := app.Render(cliFlags(), args[0])
+			log.FatalExit(err)
+			_, err = os.Stdout.Write(data)
+			log.FatalExit(err)
+			return
+		}
+		change, err := app.RenderToFile(cliFlags(), args[0], renderFile, register)
+		log.FatalExit(err)
+		renderChanges([]app.FileChange{change})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().StringVar(&renderFile, "out-file", "", "write the rendered template to a file instead of stdout")
+	addRevFlag(renderCmd)
+	renderCmd.Flags().BoolVar(&register, "register", false, "delete the rendered file when the vault is closed")
+}
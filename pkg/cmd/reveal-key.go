@@ -24,6 +24,7 @@ import (
 	"fmt"
 
 	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/spf13/cobra"
 )
 
@@ -34,8 +35,11 @@ var revealKeyCmd = &cobra.Command{
 
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		key := app.GetKey(cliFlags())
-		fmt.Println(key)
+		key, err := app.GetKey(cliFlags())
+		log.FatalExit(err)
+		render(map[string]string{"key": key}, func() {
+			fmt.Println(key)
+		})
 	},
 }
 
@@ -21,7 +21,7 @@
 package cmd
 
 import (
-	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/spf13/cobra"
 )
 
@@ -41,7 +41,9 @@ vault.
 
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		app.RemoveFromVault(cliFlags(), args)
+		changes, err := openVault().Remove(args...)
+		renderChanges(changes)
+		log.FatalExit(err)
 	},
 }
 
@@ -0,0 +1,103 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var scanAdd bool
+
+// scanCmd represents the scan command
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Find files which look like secrets but are not in the vault",
+	Long: `Search the project for files which look like they contain credentials, but are not in the vault and are not
+matched by one of its glob patterns.  Files in .lockgit and .git are skipped, as are files ignored by git.
+
+Files are reported if they contain a PEM private key, AWS credentials, or a high entropy value assigned to something
+named like a secret, token or password, or if they are .env files.
+
+With --add, you are asked whether to add each file to the vault.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// files which could not be read are reported after the findings for the rest of the project
+		findings, scanErr := app.Scan(cliFlags())
+		if findings == nil {
+			log.FatalExit(scanErr)
+		}
+		if !scanAdd {
+			render(findings, func() { scanTable(findings) })
+			log.FatalExit(scanErr)
+			return
+		}
+
+		in := bufio.NewReader(os.Stdin)
+		var paths []string
+		for _, finding := range findings {
+			fmt.Printf("add %s (%s)? [y/N] ", finding.Path, strings.Join(finding.Reasons, ", "))
+			answer, err := in.ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer == "y" || answer == "yes" {
+				paths = append(paths, finding.Path)
+			}
+			if err != nil {
+				// end of input, so do not ask about the rest
+				fmt.Println()
+				break
+			}
+		}
+		if len(paths) > 0 {
+			changes, err := app.AddScanned(cliFlags(), paths)
+			renderChanges(changes)
+			log.FatalExit(err)
+		}
+		log.FatalExit(scanErr)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+	scanCmd.Flags().BoolVar(&scanAdd, "add", false, "ask whether to add each file to the vault")
+}
+
+func scanTable(findings []app.ScanFinding) {
+	if len(findings) == 0 {
+		log.Info("no secrets found outside of the vault")
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+
+	table.SetHeader([]string{"path", "reason"})
+	for _, f := range findings {
+		table.Append([]string{f.Path, strings.Join(f.Reasons, ", ")})
+	}
+
+	table.Render()
+}
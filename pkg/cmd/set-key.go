@@ -36,8 +36,9 @@ The key for the vault can be displayed using the reveal-key command.`,
 
 	Args: cobraNamedPositionalArgs("key"),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := app.SetKey(cliFlags(), args[0])
+		record, err := app.SetKey(cliFlags(), args[0])
 		log.FatalExit(err)
+		renderKeyRecord(record)
 	},
 }
 
@@ -22,8 +22,10 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
 	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
@@ -33,23 +35,67 @@ var statusCmd = &cobra.Command{
 	Use:     "status",
 	Short:   "Check if tracked files match the ones in the vault",
 	Aliases: []string{"info"},
+	Long: `Check if tracked files match the ones in the vault.
 
-	Args: cobra.NoArgs,
-	Run: func(cmd *cobra.Command, args []string) {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetBorder(false)
+With --check, only the files which have changed or are not committed to the vault are shown, and the exit code is 2
+if there are any.  The key is required with --check.
 
-		headers, rows := app.Status(cliFlags())
+` + exitCodeHelp,
 
-		table.SetHeader(headers)
-		for _, row := range rows {
-			table.Append(row)
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if check {
+			dirty, err := app.CheckStatus(cliFlags())
+			render(dirty, func() { statusTable(dirty) })
+			log.FatalExit(err)
+			return
 		}
-
-		table.Render()
+		records, err := openVault().Status()
+		log.FatalExit(err)
+		render(records, func() { statusTable(records) })
 	},
 }
 
+var check bool
+
 func init() {
 	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&check, "check", false, "only show dirty files, and exit with code 2 if there are any")
+	addTagFlag(statusCmd)
+}
+
+func statusTable(records []app.StatusRecord) {
+	if len(records) == 0 {
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+
+	// only show the metadata columns if some secrets have metadata
+	showMeta, showDue := false, false
+	for _, r := range records {
+		showMeta = showMeta || r.Owner != "" || len(r.Tags) > 0
+		showDue = showDue || r.Due != ""
+	}
+
+	header := []string{"file", "updated", "pattern", "id"}
+	if showMeta {
+		header = append(header, "owner", "tags")
+	}
+	if showDue {
+		header = append(header, "due")
+	}
+	table.SetHeader(header)
+	for _, r := range records {
+		row := []string{r.Path, r.Updated(), r.Pattern, r.Id}
+		if showMeta {
+			row = append(row, r.Owner, strings.Join(r.Tags, ","))
+		}
+		if showDue {
+			row = append(row, string(r.Due))
+		}
+		table.Append(row)
+	}
+
+	table.Render()
 }
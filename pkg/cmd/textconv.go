@@ -0,0 +1,65 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/diff"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// textconvCmd represents the textconv command
+var textconvCmd = &cobra.Command{
+	Use:    "textconv <datafile>",
+	Hidden: true,
+	Short:  "Decrypt a datafile to text as a git diff driver",
+	Long: `Decrypt a file in .lockgit/data and print its path, permissions and contents.  Use install-git-integration to
+register it with git, so that git diff, git log -p and other tools show the changes to secrets to anyone with the key.
+
+Without the key, a digest of the encrypted file is printed instead.`,
+
+	Args: cobraNamedPositionalArgs("datafile"),
+	Run: func(cmd *cobra.Command, args []string) {
+		text, err := app.Textconv(cliFlags(), args[0])
+		log.FatalExit(err)
+		if !text.Decrypted {
+			fmt.Printf("encrypted secret %s\n", text.Digest)
+			return
+		}
+		if text.Path != "" {
+			fmt.Printf("path: %s\n", text.Path)
+			fmt.Printf("perm: %04o\n\n", text.Perm)
+		}
+		if diff.IsBinary(text.Data) {
+			fmt.Printf("binary data, %d bytes, sha256 %x\n", len(text.Data), sha256.Sum256(text.Data))
+		} else {
+			fmt.Print(string(text.Data))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(textconvCmd)
+}
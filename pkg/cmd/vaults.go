@@ -0,0 +1,147 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// vaultsCmd represents the vaults command
+var vaultsCmd = &cobra.Command{
+	Use:   "vaults",
+	Short: "Manage the vaults known to the config file",
+	Long: `Manage the vaults known to the config file.
+
+Each time a vault is used, its ID and location are recorded in the config file (~/.lockgit.yml by default) along with
+its key.  These commands list the recorded vaults, record where a vault has moved, and remove the ones which no longer
+exist.`,
+}
+
+// vaultsListCmd represents the vaults list command
+var vaultsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the vaults in the config file",
+	Aliases: []string{"ls"},
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		vaults, err := app.ListVaults(cliFlags())
+		log.FatalExit(err)
+		render(vaults, func() { vaultsTable(vaults) })
+	},
+}
+
+// vaultsPruneCmd represents the vaults prune command
+var vaultsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove vaults which no longer exist from the config file",
+	Long: `Remove vaults which no longer exist from the config file.
+
+Vaults with a saved key are kept unless --keys is given.  Removing them irrevocably deletes their keys, so --keys also
+requires --force.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pruned, err := app.PruneVaults(cliFlags(), pruneKeys)
+		log.FatalExit(err)
+		render(pruned, func() {
+			for _, vault := range pruned {
+				log.Infof("%s %s at %s", dryRunVerb("removed", "would remove"), vault.Id, vault.Path)
+			}
+			if dryRun {
+				log.Info("dry run - no files were changed")
+			}
+		})
+	},
+}
+
+// vaultsRelocateCmd represents the vaults relocate command
+var vaultsRelocateCmd = &cobra.Command{
+	Use:   "relocate <id> <path>",
+	Short: "Record that a vault has moved to another directory",
+	Long: `Record that a vault has moved to another directory.  The vault in the directory must have the same ID.
+
+Using a vault also records where it is, so this is only needed to keep a moved vault from being pruned before it is
+used again.`,
+
+	Args: cobraNamedPositionalArgs("id", "path"),
+	Run: func(cmd *cobra.Command, args []string) {
+		vault, err := app.RelocateVault(cliFlags(), args[0], args[1])
+		log.FatalExit(err)
+		render(vault, func() {
+			log.Infof("%s %s to %s", dryRunVerb("moved", "would move"), vault.Id, vault.Path)
+			if dryRun {
+				log.Info("dry run - no files were changed")
+			}
+		})
+	},
+}
+
+// vaultsForgetCmd represents the vaults forget command
+var vaultsForgetCmd = &cobra.Command{
+	Use:   "forget <id>",
+	Short: "Remove a vault from the config file",
+	Long: `Remove a vault from the config file.  If the key for the vault is saved, it is irrevocably deleted, so --force
+is required.`,
+
+	Args: cobraNamedPositionalArgs("id"),
+	Run: func(cmd *cobra.Command, args []string) {
+		vault, err := app.ForgetVault(cliFlags(), args[0])
+		log.FatalExit(err)
+		render(vault, func() {
+			log.Infof("%s %s at %s", dryRunVerb("removed", "would remove"), vault.Id, vault.Path)
+			if dryRun {
+				log.Info("dry run - no files were changed")
+			}
+		})
+	},
+}
+
+var pruneKeys bool
+
+func init() {
+	rootCmd.AddCommand(vaultsCmd)
+	vaultsCmd.AddCommand(vaultsListCmd)
+	vaultsCmd.AddCommand(vaultsPruneCmd)
+	vaultsCmd.AddCommand(vaultsRelocateCmd)
+	vaultsCmd.AddCommand(vaultsForgetCmd)
+	vaultsPruneCmd.Flags().BoolVar(&pruneKeys, "keys", false, "also remove vaults with a saved key")
+	addForceFlag(vaultsPruneCmd, "allow deleting the keys of vaults which no longer exist")
+	addForceFlag(vaultsForgetCmd, "allow deleting the key of the vault")
+}
+
+func vaultsTable(vaults []app.VaultRecord) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+
+	table.SetHeader([]string{"id", "path", "key", "exists"})
+	for _, v := range vaults {
+		table.Append([]string{v.Id, v.Path, strconv.FormatBool(v.HasKey), strconv.FormatBool(v.Exists)})
+	}
+
+	table.Render()
+}
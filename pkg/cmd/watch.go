@@ -0,0 +1,69 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Commit changes to the vault as files are saved",
+	Long: `Watch the files in the vault and the directories covered by the saved glob patterns, and commit changes to the
+vault as they happen.  Changed files are re-encrypted and new files matching a glob pattern are added, as with the
+commit command.  Glob patterns added to the vault while watching are picked up.
+
+Events are debounced, so saving several files at once results in a single commit.  Stop watching with Ctrl-C.`,
+
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		err := app.Watch(cliFlags(), debounce, stop, nil, func(changes []app.FileChange, err error) {
+			if len(changes) > 0 {
+				renderChanges(changes)
+			}
+			log.LogError(err)
+		})
+		log.FatalExit(err)
+	},
+}
+
+var debounce time.Duration
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().DurationVar(&debounce, "debounce", 500*time.Millisecond, "time to wait for more changes before committing")
+}
@@ -26,11 +26,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 
 	"github.com/jswidler/lockgit/pkg/app"
 	"github.com/jswidler/lockgit/pkg/build"
+	"github.com/jswidler/lockgit/pkg/keystore"
+	"github.com/jswidler/lockgit/pkg/lockgit"
 	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
@@ -42,31 +45,81 @@ var cfgFile string
 var noUpdateGitignore bool
 var wd string
 var force bool
+var rev string
+var exclude []string
+var dryRun bool
+var profile string
+var tags []string
+var rotateEvery string
+var jobs int
+var keyStore keystore.KeyStore
 
 func cliFlags() app.Options {
 	return app.Options{
 		Wd:                wd,
 		NoUpdateGitignore: noUpdateGitignore,
 		Force:             force,
+		Rev:               rev,
+		Exclude:           exclude,
+		DryRun:            dryRun,
+		Profile:           profile,
+		Tags:              tags,
+		RotateEvery:       rotateEvery,
+		Jobs:              jobs,
+		KeyStore:          keyStore,
 	}
 }
 
+// Open the vault in the working directory with the options from the command line flags
+func openVault() *lockgit.Vault {
+	vault, err := lockgit.Open(wd, lockgit.Options{
+		Profile:           profile,
+		Force:             force,
+		DryRun:            dryRun,
+		NoUpdateGitignore: noUpdateGitignore,
+		Exclude:           exclude,
+		Tags:              tags,
+		Rev:               rev,
+		RotateEvery:       rotateEvery,
+		Jobs:              jobs,
+		KeyStore:          keyStore,
+	})
+	log.FatalExit(err)
+	return vault
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "lockgit",
 	Short: "A secret vault for git repos",
+	Long:  "A secret vault for git repos\n\n" + exitCodeHelp,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		var err error
 		wd, err = os.Getwd()
 		log.FatalPanic(err)
+		log.FatalExit(setupOutput())
 	},
 }
 
+const exitCodeHelp = `Exit codes:
+  0  success, or the vault is clean
+  1  the command failed
+  2  status --check found secrets which have changed or are not committed to the vault, or gitignore check
+     found secrets which git would not ignore, or due found secrets which have expired or should be rotated
+  3  the command failed for some files but not others
+  4  the key for the vault could not be loaded
+  5  no vault was found in the working directory or its parents`
+
+// Returns the root command, with every other command below it
+func Command() *cobra.Command {
+	return rootCmd
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(log.ExitError)
 	}
 }
 
@@ -75,9 +128,10 @@ var cmdOrder = []string{
 	"init",
 	"set-key", "reveal-key", "delete-key",
 	"add", "mv", "rm",
-	"status", "commit",
-	"open", "close",
-	"ls", "globs",
+	"status", "commit", "watch", "due",
+	"open", "close", "cat", "render",
+	"annotate", "ls", "globs", "scan", "vaults", "profile",
+	"log", "install-git-integration", "hook", "filter", "gitignore",
 }
 
 func init() {
@@ -92,9 +146,18 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ~/.lockgit.yml)")
 	rootCmd.PersistentFlags().BoolVarP(&noUpdateGitignore, "no-update-gitignore", "", false, "disable updating .gitignore file")
 	viper.BindPFlag("no-update-gitignore", rootCmd.PersistentFlags().Lookup("no-update-gitignore"))
+	addOutputFormatFlag(rootCmd)
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "use a profile of the vault, which has its own key and secrets")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print errors")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "also print files which were skipped or are being watched")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "trace where keys are read from and which files are read and written")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show what add, rm, commit, open, close and vaults prune would change without changing any files")
+	rootCmd.PersistentFlags().IntVarP(&jobs, "jobs", "j", runtime.GOMAXPROCS(0), "how many files to encrypt or decrypt at once")
 }
 
 func initConfig() {
+	// before the config is read, so reading it can be traced
+	log.FatalExit(setupLogging())
 	InitConfig(cfgFile)
 }
 
@@ -120,6 +183,10 @@ func InitConfig(file string) {
 
 		noUpdateGitignore = viper.GetBool("no-update-gitignore")
 	}
+
+	var err error
+	keyStore, err = keystore.FromConfig(viper.ConfigFileUsed())
+	log.FatalExit(err)
 }
 
 // Return a validator for named positional arguments from the command-line
@@ -174,3 +241,15 @@ func (c cmdList) Swap(i, j int) {
 func addForceFlag(cmd *cobra.Command, msg string) {
 	cmd.Flags().BoolVarP(&force, "force", "f", false, msg)
 }
+
+func addRevFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&rev, "rev", "", "read secrets from a git revision instead of the working directory")
+}
+
+func addExcludeFlag(cmd *cobra.Command, msg string) {
+	cmd.Flags().StringArrayVarP(&exclude, "exclude", "x", nil, msg)
+}
+
+func addTagFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&tags, "tag", "t", nil, "only use secrets with the tag, which can be given more than once")
+}
@@ -27,10 +27,10 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/jswidler/lockgit/pkg/keystore"
 	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/jswidler/lockgit/pkg/util"
 	"github.com/pkg/errors"
-	"github.com/spf13/viper"
 )
 
 type Context struct {
@@ -40,29 +40,49 @@ type Context struct {
 	DataPath    string   // path to .lockgit/data
 	ConfigPath  string   // path to .lockgit/data/lgconfig
 	Config      LgConfig // Config data
+	Source      Source   // where the manifest and datafiles are read from, the working directory if nil
 
-	Key []byte // key bytes loaded from lgconfig (if key is present)
+	Profile      string // name of the active profile, or "" for the default profile
+	ManifestPath string // path to the manifest of the active profile
+
+	Key      []byte            // key bytes loaded from the key store (if key is present)
+	KeyStore keystore.KeyStore // where the key and the path of the vault are saved
+}
+
+// A Source provides the contents of files in the project, such as the files in a git revision
+type Source interface {
+	// Read a file given its path relative to the project root.  Returns an error satisfying
+	// os.IsNotExist if the file does not exist in the source.
+	ReadFile(projRelPath string) ([]byte, error)
 }
 
 // Return a Context provided a base to begin traversal from.
 // The context will be from the first .lockgit directory found
-func FromPath(path string) (Context, error) {
-	c := Context{}
+func FromPath(path string, store keystore.KeyStore) (Context, error) {
+	return FromProfile(path, "", store)
+}
+
+// Like FromPath, but the context is for one of the vault's profiles.  Each profile has its own key, manifest
+// and patterns, which are kept in .lockgit/profiles/<name>.  The datafiles of every profile share .lockgit/data.
+func FromProfile(path string, profile string, store keystore.KeyStore) (Context, error) {
+	c := Context{KeyStore: store}
 
 	pathabs, err := filepath.Abs(path)
 	if err != nil {
-		log.FatalPanic(errors.Wrap(err, "could not make absolute path"))
+		return c, errors.Wrap(err, "could not make absolute path")
 	}
 
 	lockgitPath, err := findLockgit(pathabs)
 	if err != nil {
 		return c, err
 	}
+	log.Debugf("found vault at %s", lockgitPath)
 	c.WorkingPath = pathabs
 	c.LockgitPath = lockgitPath
 	c.ProjectPath = filepath.Dir(lockgitPath)
 	c.DataPath = filepath.Join(lockgitPath, "data")
 	c.ConfigPath = filepath.Join(c.LockgitPath, "lgconfig")
+	c.ManifestPath = filepath.Join(c.LockgitPath, "manifest")
 
 	c.Config, err = ReadConfig(c)
 	if os.IsNotExist(err) {
@@ -70,42 +90,94 @@ func FromPath(path string) (Context, error) {
 		// v0.5 -> v0.6+:  For now, assume this file is missing because it was created with an old version of lockgit.
 		// 				  Update the lockgit vault by creating a the config file and moving the key if it exists
 		c.Config = NewLgConfig()
-		c.Config.Write(c.ConfigPath)
+		if err := c.Config.Write(c.ConfigPath); err != nil {
+			return c, err
+		}
 		key, err := readKeyOldV05(c)
 		if err == nil {
 			keyStr := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
-			viper.Set("vaults."+c.Config.Id+".key", keyStr)
-			viper.Set("vaults."+c.Config.Id+".path", c.ProjectPath)
-			viper.WriteConfig()
+			_ = store.Set(keystore.Entry{Id: c.Config.Id, Key: keyStr, Path: c.ProjectPath})
 		}
 	} else if err != nil {
-		log.FatalPanic(errors.Wrap(err, "could not read .lockgit/lgconfig"))
+		return c, errors.Wrap(err, "could not read .lockgit/lgconfig")
+	}
+
+	// Update path in the key store if it has changed.  Some key stores cannot be changed, so errors are ignored.
+	entry, err := store.Get(c.Config.Id, "")
+	if err == nil && c.ProjectPath != entry.Path {
+		entry.Path = c.ProjectPath
+		_ = store.Set(entry)
 	}
 
-	// Update path in config file if it has changed
-	pathKey := "vaults." + c.Config.Id + ".path"
-	if c.ProjectPath != viper.GetString(pathKey) {
-		viper.Set(pathKey, c.ProjectPath)
-		viper.WriteConfig()
+	if profile != "" {
+		if err := c.loadProfile(profile); err != nil {
+			return c, err
+		}
 	}
 
 	c.Key, err = readKey(c)
 	return c, err
 }
 
+// Switch the context to one of the vault's profiles
+func (c *Context) loadProfile(profile string) error {
+	if !c.Config.HasProfile(profile) {
+		return &ProfileNotFoundError{profile}
+	}
+	profilePath := ProfilePath(*c, profile)
+	c.Profile = profile
+	c.ConfigPath = filepath.Join(profilePath, "lgconfig")
+	c.ManifestPath = filepath.Join(profilePath, "manifest")
+
+	vaultId := c.Config.Id
+	config, err := ReadConfig(*c)
+	if os.IsNotExist(err) {
+		// nothing has been added to the profile yet
+		config = NewLgConfig()
+		config.Id = vaultId
+	} else if err != nil {
+		return errors.Wrapf(err, "could not read the config of profile %s", profile)
+	}
+	c.Config = config
+	return nil
+}
+
+// Returns the directory holding the config and manifest of a profile
+func ProfilePath(c Context, profile string) string {
+	return filepath.Join(c.LockgitPath, "profiles", profile)
+}
+
+// Returns the entry in the key store for the active profile
+func (c Context) KeyEntry() (keystore.Entry, error) {
+	return c.KeyStore.Get(c.Config.Id, c.Profile)
+}
+
+// Describes the vault, and the profile if one is active, in messages
+func (c Context) describe() string {
+	if c.Profile != "" {
+		return fmt.Sprintf("profile %s of %s", c.Profile, c.ProjectPath)
+	}
+	return c.ProjectPath
+}
+
 func readKey(c Context) ([]byte, error) {
-	keyStr := viper.GetString("vaults." + c.Config.Id + ".key")
+	log.Debugf("reading the key for %s from %s", c.describe(), c.KeyStore)
+	entry, err := c.KeyEntry()
+	if err != nil {
+		return nil, &KeyLoadError{fmt.Sprintf("error attempting to read key for %s in %s: %s", c.describe(), c.KeyStore, err.Error())}
+	}
 
-	if keyStr == "" {
-		return nil, &KeyLoadError{fmt.Sprintf("no key for %s found in %s", c.ProjectPath, viper.ConfigFileUsed())}
+	if entry.Key == "" {
+		return nil, &KeyLoadError{fmt.Sprintf("no key for %s found in %s", c.describe(), c.KeyStore)}
 	}
 
-	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(keyStr)
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(entry.Key)
 	if err != nil {
-		return nil, &KeyLoadError{fmt.Sprintf("error attempting to read key for %s in %s: %s", c.ProjectPath, viper.ConfigFileUsed(), err.Error())}
+		return nil, &KeyLoadError{fmt.Sprintf("error attempting to read key for %s in %s: %s", c.describe(), c.KeyStore, err.Error())}
 	} else if len(key) != 32 {
-		return key, &KeyLoadError{fmt.Sprintf("key for %s in %s is the wrong size", c.ProjectPath, viper.ConfigFileUsed())}
+		return key, &KeyLoadError{fmt.Sprintf("key for %s in %s is the wrong size", c.describe(), c.KeyStore)}
 	}
+	log.Debugf("found the key for %s", c.describe())
 	return key, nil
 }
 
@@ -126,6 +198,7 @@ func readKeyOldV05(c Context) ([]byte, error) {
 // in the provided path, each parent directory will be searched until one is found.
 // Returns the path or an error if none is found.
 func findLockgit(path string) (string, error) {
+	start := path
 	for {
 		lockgitPath := filepath.Join(path, ".lockgit")
 		if exist, _ := util.ExistsDir(lockgitPath); exist {
@@ -133,7 +206,7 @@ func findLockgit(path string) (string, error) {
 		}
 		path = filepath.Dir(path)
 		if path == "/" {
-			return "", errors.New("no lockgit vault found")
+			return "", &VaultNotFoundError{start}
 		}
 	}
 }
@@ -142,6 +215,15 @@ func (c Context) ImportManifest() (Manifest, error) {
 	return ImportManifest(c)
 }
 
+// Read a file in the project from the context's source
+func (c Context) ReadFile(absPath string) ([]byte, error) {
+	log.Debugf("reading %s", c.ProjRelPath(absPath))
+	if c.Source == nil {
+		return ioutil.ReadFile(absPath)
+	}
+	return c.Source.ReadFile(c.ProjRelPath(absPath))
+}
+
 func (c Context) RelPath(absPath string) string {
 	path, err := filepath.Rel(c.WorkingPath, absPath)
 	if err != nil {
@@ -25,7 +25,9 @@ import (
 	"compress/zlib"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -33,7 +35,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"reflect"
+	"time"
 
 	"github.com/jswidler/lockgit/pkg/log"
 	"github.com/pkg/errors"
@@ -49,6 +51,37 @@ type dcontent struct {
 	Data string
 	Path string
 	Perm int
+	Meta *Metadata `json:",omitempty"`
+}
+
+// Information about a secret which is encrypted along with it.  Datafiles written by older versions
+// of lockgit do not have any.
+type Metadata struct {
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Owner       string    `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Tags        []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Created     time.Time `json:"created" yaml:"created"` // when the secret was first added to the vault
+	Updated     time.Time `json:"updated" yaml:"updated"` // when the contents were last committed to the vault
+
+	RotateEvery string     `json:"rotateEvery,omitempty" yaml:"rotateEvery,omitempty"` // such as 90d, see util.ParseDuration
+	Expires     *time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+}
+
+// True if the metadata has every one of the tags
+func (m Metadata) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range m.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 func NewDatafile(ctx Context, absPath string) (Datafile, error) {
@@ -64,16 +97,20 @@ func NewDatafile(ctx Context, absPath string) (Datafile, error) {
 	if err != nil {
 		return d, errors.Wrap(err, "unable to read")
 	}
-	datafile := Datafile{
+	return NewDatafileFromData(ctx, relPath, filedata, info.Mode().Perm()), nil
+}
+
+// Make a Datafile from data which is already in memory.  The path is relative to the project.
+func NewDatafileFromData(ctx Context, relPath string, data []byte, perm os.FileMode) Datafile {
+	return Datafile{
 		ctx: &ctx,
 		content: dcontent{
 			Ver:  1,
-			Data: base64.RawStdEncoding.EncodeToString(filedata),
+			Data: base64.RawStdEncoding.EncodeToString(data),
 			Path: relPath,
-			Perm: int(info.Mode().Perm()),
+			Perm: int(perm),
 		},
 	}
-	return datafile, nil
 }
 
 func (d Datafile) Path() string {
@@ -84,6 +121,18 @@ func (d Datafile) Perm() int {
 	return d.content.Perm
 }
 
+// Returns the metadata of the secret, which is empty if it has none
+func (d Datafile) Meta() Metadata {
+	if d.content.Meta == nil {
+		return Metadata{}
+	}
+	return *d.content.Meta
+}
+
+func (d *Datafile) SetMeta(meta Metadata) {
+	d.content.Meta = &meta
+}
+
 func (d Datafile) Serialize() ([]byte, error) {
 	jsondata, err := json.Marshal(d.content)
 	if err != nil {
@@ -92,12 +141,33 @@ func (d Datafile) Serialize() ([]byte, error) {
 	return encrypt(d.ctx.Key, compress(jsondata))
 }
 
+// Serialize with an initialization vector derived from the contents, so the same contents always produce
+// the same ciphertext.  This is used where the output must be stable, such as a git clean filter.
+func (d Datafile) SerializeDeterministic() ([]byte, error) {
+	jsondata, err := json.Marshal(d.content)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, deriveKey(d.ctx.Key, "lockgit-filter-iv"))
+	mac.Write(jsondata)
+	iv := mac.Sum(nil)[:aes.BlockSize]
+	return encryptWithIV(d.ctx.Key, iv, compress(jsondata))
+}
+
+// Derive a key for another purpose from the vault key, so the vault key itself is only used for encryption
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
 func (d Datafile) Write(filemeta Filemeta) error {
 	path := MakeDatafilePath(*d.ctx, filemeta)
 	ciphertext, err := d.Serialize()
 	if err != nil {
 		return err
 	}
+	log.Debugf("writing %s for %s", d.ctx.ProjRelPath(path), d.content.Path)
 	return ioutil.WriteFile(path, ciphertext, 0644)
 }
 
@@ -122,19 +192,31 @@ func (d Datafile) MatchesCurrent(filemeta Filemeta) (bool, error) {
 	return currentDatafile.Equal(d), nil
 }
 
+// True if the datafiles have the same contents, path and permissions.  The metadata is not compared.
 func (d Datafile) Equal(other Datafile) bool {
-	return reflect.DeepEqual(d, other)
+	return d.content.Data == other.content.Data && d.content.Path == other.content.Path &&
+		d.content.Perm == other.content.Perm
+}
+
+// True if the datafiles have the same contents.  The path, permissions and metadata are not compared.
+func (d Datafile) SameData(other Datafile) bool {
+	return d.content.Data == other.content.Data
 }
 
 func ReadDatafile(ctx Context, filemeta Filemeta) (Datafile, error) {
+	ciphertext, err := ctx.ReadFile(MakeDatafilePath(ctx, filemeta))
+	if err != nil {
+		return Datafile{ctx: &ctx, content: dcontent{}}, err
+	}
+	return DecodeDatafile(ctx, ciphertext)
+}
+
+// Decrypt and decode the contents of a datafile which has already been read into memory.
+func DecodeDatafile(ctx Context, ciphertext []byte) (Datafile, error) {
 	data := Datafile{
 		ctx:     &ctx,
 		content: dcontent{},
 	}
-	ciphertext, err := ioutil.ReadFile(MakeDatafilePath(ctx, filemeta))
-	if err != nil {
-		return data, err
-	}
 	compressed, err := decrypt(ctx.Key, ciphertext)
 	if err != nil {
 		return data, err
@@ -178,16 +260,21 @@ func decompress(data []byte) ([]byte, error) {
 }
 
 func encrypt(key, plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	iv := make([]byte, aes.BlockSize)
+	_, err := io.ReadFull(rand.Reader, iv)
 	if err != nil {
 		return nil, err
 	}
-	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
-	iv := ciphertext[:aes.BlockSize]
-	_, err = io.ReadFull(rand.Reader, iv)
+	return encryptWithIV(key, iv, plaintext)
+}
+
+func encryptWithIV(key, iv, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
+	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
+	copy(ciphertext, iv)
 	stream := cipher.NewCFBEncrypter(block, iv)
 	stream.XORKeyStream(ciphertext[aes.BlockSize:], plaintext)
 	return ciphertext, nil
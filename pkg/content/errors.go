@@ -22,6 +22,8 @@ package content
 
 import (
 	"fmt"
+
+	"github.com/jswidler/lockgit/pkg/log"
 )
 
 type KeyLoadError struct {
@@ -32,6 +34,10 @@ func (err *KeyLoadError) Error() string {
 	return err.message
 }
 
+func (err *KeyLoadError) ExitCode() int {
+	return log.ExitKeyMissing
+}
+
 func IsKeyLoadError(err error) bool {
 	if err != nil {
 		switch err.(type) {
@@ -60,3 +66,33 @@ func IsManifestLoadError(err error) bool {
 	}
 	return false
 }
+
+type VaultNotFoundError struct {
+	path string
+}
+
+func (err *VaultNotFoundError) Error() string {
+	return "no lockgit vault found"
+}
+
+func (err *VaultNotFoundError) ExitCode() int {
+	return log.ExitVaultNotFound
+}
+
+func IsVaultNotFoundError(err error) bool {
+	if err != nil {
+		switch err.(type) {
+		case *VaultNotFoundError:
+			return true
+		}
+	}
+	return false
+}
+
+type ProfileNotFoundError struct {
+	name string
+}
+
+func (err *ProfileNotFoundError) Error() string {
+	return fmt.Sprintf("the vault has no profile named %s", err.name)
+}
@@ -0,0 +1,54 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package content
+
+import (
+	"bytes"
+	"os"
+)
+
+// Files encrypted by the git clean filter begin with this header, so they can be told apart from
+// plaintext which was committed before the filter was configured.
+var filterHeader = []byte("\x00LOCKGIT\x00")
+
+// The permissions recorded for filtered files.  Git tracks the permissions of files in filter mode.
+const filterPerm = os.FileMode(0644)
+
+// Tests if data was encrypted by the git clean filter
+func IsFiltered(data []byte) bool {
+	return bytes.HasPrefix(data, filterHeader)
+}
+
+// Encrypt the contents of a file for the git clean filter.  The output is deterministic, so that a file
+// which has not changed does not appear modified to git.  The path is relative to the project.
+func EncryptFiltered(ctx Context, relPath string, data []byte) ([]byte, error) {
+	ciphertext, err := NewDatafileFromData(ctx, relPath, data, filterPerm).SerializeDeterministic()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, filterHeader...), ciphertext...), nil
+}
+
+// Decrypt the output of the git clean filter.  The data is not modified, so it can still be passed through
+// if it cannot be decrypted.
+func DecryptFiltered(ctx Context, data []byte) (Datafile, error) {
+	return DecodeDatafile(ctx, append([]byte{}, bytes.TrimPrefix(data, filterHeader)...))
+}
@@ -0,0 +1,253 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package content
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// The local index records the state of each plaintext file when it was last found to match its secret in the
+// vault, like the git index.  While the file's size, modification time, inode and mode stay the same, it is
+// known to still match without decrypting the datafile.  It also caches the permissions and metadata of each
+// secret for as long as its datafile is unchanged.  The metadata is encrypted with a key derived from the vault
+// key, like the hashes.  Without the key, the index can still tell whether files have changed and what their
+// permissions are, which is deliberate since neither reveals the contents of a secret.
+//
+// The index is saved in .lockgit/local, which is ignored by git since it describes the files on this machine.
+// Profiles have their own index, see IndexPath.
+// It is safe to use from several goroutines at once.  A nil *Index is an empty index which is never saved.
+type Index struct {
+	entries  map[string]IndexEntry // by the id of the secret's datafile
+	path     string
+	dataPath string
+	changed  bool
+	mu       sync.Mutex
+}
+
+type IndexEntry struct {
+	Datafile   FileStat `json:"datafile"`
+	Perm       int      `json:"perm"`
+	Meta       Metadata `json:"-"`    // decrypted from SealedMeta when the index is read with the key
+	SealedMeta []byte   `json:"meta"` // the encrypted metadata of the secret
+
+	// The state of the plaintext file when it matched the secret, or nil if it was not open
+	Plaintext *FileStat `json:"plaintext,omitempty"`
+	Hash      string    `json:"hash,omitempty"` // HMAC-SHA256 of the plaintext, keyed with a key derived from the vault key
+	Checked   int64     `json:"checked"`        // when the entry was recorded, in unix nanoseconds
+}
+
+type FileStat struct {
+	Size  int64       `json:"size"`
+	Mtime int64       `json:"mtime"` // unix nanoseconds
+	Inode uint64      `json:"inode"`
+	Mode  os.FileMode `json:"mode"`
+}
+
+// Returns the path of the local index.  Each profile has its own index, since the metadata and hashes are keyed
+// with the key of the profile, and a shared index would be discarded whenever another profile is used.
+func IndexPath(ctx Context) string {
+	if ctx.Profile != "" {
+		return filepath.Join(ctx.LockgitPath, "local", "index."+ctx.Profile)
+	}
+	return filepath.Join(ctx.LockgitPath, "local", "index")
+}
+
+// Read the local index of the vault.  The index is only a cache, so if it is missing or cannot be read an
+// empty index is returned.  Entries whose metadata cannot be decrypted with the key are dropped.
+func ReadIndex(ctx Context) *Index {
+	idx := &Index{entries: make(map[string]IndexEntry), path: IndexPath(ctx), dataPath: ctx.DataPath}
+	data, err := ioutil.ReadFile(idx.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debugf("ignoring local index: %s", err)
+		}
+		return idx
+	}
+	if err := json.Unmarshal(data, &idx.entries); err != nil {
+		log.Debugf("ignoring local index: %s", err)
+		idx.entries = make(map[string]IndexEntry)
+	}
+	if ctx.Key != nil {
+		for id, entry := range idx.entries {
+			if err := entry.unsealMeta(ctx.Key); err != nil {
+				log.Debugf("ignoring index entry for %s: %s", id, err)
+				delete(idx.entries, id)
+				continue
+			}
+			idx.entries[id] = entry
+		}
+	}
+	return idx
+}
+
+// Write the index if it has changed.  Entries for datafiles which no longer exist are dropped.
+func (idx *Index) Save() error {
+	if idx == nil {
+		return nil
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.changed {
+		return nil
+	}
+	for id := range idx.entries {
+		if _, err := os.Lstat(filepath.Join(idx.dataPath, id)); os.IsNotExist(err) {
+			delete(idx.entries, id)
+		}
+	}
+	data, err := json.Marshal(idx.entries)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(idx.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "unable to write local index")
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Lstat(ignore); os.IsNotExist(err) {
+		_ = ioutil.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	log.Debugf("writing %s", idx.path)
+	tmp := idx.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "unable to write local index")
+	}
+	idx.changed = false
+	return errors.Wrap(os.Rename(tmp, idx.path), "unable to write local index")
+}
+
+// Returns the entry for a secret, if there is one
+func (idx *Index) Get(filemeta Filemeta) (IndexEntry, bool) {
+	if idx == nil {
+		return IndexEntry{}, false
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	entry, ok := idx.entries[filemeta.IdString()]
+	return entry, ok
+}
+
+// Record that the plaintext matches the secret, which has the permissions and metadata given.  If plaintext is
+// nil, only the secret is recorded, such as when its file has been closed.
+func (idx *Index) Record(ctx Context, filemeta Filemeta, plaintext []byte, perm int, meta Metadata) {
+	if idx == nil {
+		return
+	}
+	entry := IndexEntry{Perm: perm, Meta: meta, Checked: time.Now().UnixNano()}
+	var err error
+	if ctx.Key != nil {
+		entry.SealedMeta, err = sealMeta(ctx.Key, meta)
+	}
+	if err == nil {
+		entry.Datafile, err = Stat(MakeDatafilePath(ctx, filemeta))
+	}
+	if err == nil && plaintext != nil && ctx.Key != nil {
+		var stat FileStat
+		stat, err = Stat(filemeta.AbsPath)
+		entry.Plaintext = &stat
+		entry.Hash = plaintextHash(ctx.Key, plaintext)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.changed = true
+	if err != nil {
+		delete(idx.entries, filemeta.IdString())
+		return
+	}
+	if ctx.Key == nil {
+		// the metadata cannot be encrypted without the key, so keep what was recorded with it
+		if old, ok := idx.entries[filemeta.IdString()]; ok && old.Datafile == entry.Datafile {
+			entry.Meta, entry.SealedMeta = old.Meta, old.SealedMeta
+		}
+	}
+	idx.entries[filemeta.IdString()] = entry
+}
+
+// True if the datafile has not changed since the entry was recorded, so its permissions and metadata are current
+func (e IndexEntry) SecretCurrent(ctx Context, filemeta Filemeta) bool {
+	stat, err := Stat(MakeDatafilePath(ctx, filemeta))
+	return err == nil && stat == e.Datafile
+}
+
+// True if neither the plaintext file nor the datafile have changed since the entry was recorded, so the
+// plaintext still matches the secret.  Like git, a file modified in the same second the entry was recorded is
+// not trusted, since a later change in that second may not change its modification time.
+func (e IndexEntry) Unchanged(ctx Context, filemeta Filemeta) bool {
+	if e.Plaintext == nil || !e.SecretCurrent(ctx, filemeta) {
+		return false
+	}
+	stat, err := Stat(filemeta.AbsPath)
+	if err != nil || stat != *e.Plaintext {
+		return false
+	}
+	return stat.Mtime < time.Unix(0, e.Checked).Truncate(time.Second).UnixNano()
+}
+
+// True if the plaintext and its permissions are the same as when the entry was recorded, and the datafile has not
+// changed.  This is used when the plaintext file was modified without changing it, such as by touch.
+func (e IndexEntry) Matches(ctx Context, filemeta Filemeta, plaintext []byte, perm int) bool {
+	return e.Hash != "" && ctx.Key != nil && perm == e.Perm && e.SecretCurrent(ctx, filemeta) &&
+		hmac.Equal([]byte(e.Hash), []byte(plaintextHash(ctx.Key, plaintext)))
+}
+
+// Returns the stat data which the index compares to find changed files
+func Stat(absPath string) (FileStat, error) {
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return FileStat{}, err
+	}
+	return FileStat{Size: info.Size(), Mtime: info.ModTime().UnixNano(), Inode: inode(info), Mode: info.Mode()}, nil
+}
+
+func plaintextHash(key, plaintext []byte) string {
+	mac := hmac.New(sha256.New, deriveKey(key, "lockgit-index-hash"))
+	mac.Write(plaintext)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func sealMeta(key []byte, meta Metadata) ([]byte, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(deriveKey(key, "lockgit-index-meta"), data)
+}
+
+func (e *IndexEntry) unsealMeta(key []byte) error {
+	data, err := decrypt(deriveKey(key, "lockgit-index-meta"), e.SealedMeta)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &e.Meta)
+}
@@ -23,9 +23,12 @@ package content
 import (
 	"encoding/json"
 	"io/ioutil"
-	"sort"
+	"strings"
 
+	"github.com/jswidler/lockgit/pkg/gitignore"
+	"github.com/jswidler/lockgit/pkg/keystore"
 	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/jswidler/lockgit/pkg/util"
 	"github.com/nu7hatch/gouuid"
 	"github.com/pkg/errors"
 )
@@ -34,26 +37,34 @@ type LgConfig struct {
 	Ver      int
 	Id       string
 	Patterns []string
+
+	// Patterns are matched like lines in a .gitignore file at the project root.  Vaults created before this
+	// was added leave it unset, and their patterns are matched by doublestar against the whole path instead.
+	GitignoreSyntax bool `json:",omitempty"`
+
+	// Files rendered from templates by the render command, relative to the project.  They are deleted by close.
+	Rendered []string `json:",omitempty"`
+
+	// Names of the vault's profiles.  Only set in the config at the root of .lockgit.
+	Profiles []string `json:",omitempty"`
 }
 
 func NewLgConfig() LgConfig {
 	id, err := uuid.NewV4()
 	log.FatalPanic(err)
 	return LgConfig{
-		Ver:      1,
-		Id:       id.String(),
-		Patterns: nil,
+		Ver:             1,
+		Id:              id.String(),
+		Patterns:        nil,
+		GitignoreSyntax: true,
 	}
 }
 
+// Add a pattern to the end of the list if it is not already in it.  The order of the patterns matters
+// because negated patterns only exclude files matched by the patterns before them.
 func (c *LgConfig) AddPattern(pattern string) bool {
-	if c.Patterns == nil {
-		c.Patterns = []string{pattern}
-		return true
-	}
 	if c.FindPattern(pattern) < 0 {
 		c.Patterns = append(c.Patterns, pattern)
-		sort.Strings(c.Patterns)
 		return true
 	}
 	return false
@@ -69,18 +80,71 @@ func (c *LgConfig) RemovePattern(pattern string) bool {
 }
 
 func (c LgConfig) FindPattern(path string) int {
-	i := sort.Search(len(c.Patterns), func(i int) bool { return c.Patterns[i] >= path })
-	if i < len(c.Patterns) && c.Patterns[i] == path {
-		return i
+	for i, pattern := range c.Patterns {
+		if pattern == path {
+			return i
+		}
 	}
 	return -1
 }
 
-func (config LgConfig) Write(path string) {
+// Add a rendered file to the config if it is not already in it
+func (c *LgConfig) AddRendered(path string) bool {
+	for _, rendered := range c.Rendered {
+		if rendered == path {
+			return false
+		}
+	}
+	c.Rendered = append(c.Rendered, path)
+	return true
+}
+
+// True if the vault has a profile with the name
+func (c LgConfig) HasProfile(name string) bool {
+	for _, profile := range c.Profiles {
+		if profile == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the first saved pattern which matches a path relative to the project, or "" if none match or the
+// path is excluded by a negated pattern
+func (c LgConfig) MatchPattern(path string) (string, error) {
+	return c.MatchPatterns(path, c.Patterns)
+}
+
+// Like MatchPattern, but for patterns which are not saved in the config
+func (c LgConfig) MatchPatterns(path string, patterns []string) (string, error) {
+	if c.GitignoreSyntax {
+		return gitignore.MatchPatterns(path, false, patterns), nil
+	}
+	return util.MatchPatterns(path, patterns)
+}
+
+// Returns the absolute paths of the files in the project which are matched by the saved patterns
+func (c LgConfig) PatternFiles(projectPath string) ([]string, error) {
+	if c.GitignoreSyntax {
+		return gitignore.Files(projectPath, c.Patterns)
+	}
+	return util.GetPatternFiles(projectPath, c.Patterns)
+}
+
+// Returns a doublestar glob for a saved pattern, ignoring any negation
+func (c LgConfig) PatternGlob(pattern string) string {
+	if c.GitignoreSyntax {
+		return gitignore.ToGlob(pattern)
+	}
+	return strings.TrimPrefix(pattern, "!")
+}
+
+func (config LgConfig) Write(path string) error {
 	filedata, err := json.Marshal(config)
-	log.FatalPanic(err)
-	err = ioutil.WriteFile(path, filedata, 0644)
-	log.FatalPanic(err)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(path, filedata, 0644), "unable to write config")
 }
 
 func ReadConfig(ctx Context) (LgConfig, error) {
@@ -98,6 +162,9 @@ func ReadConfig(ctx Context) (LgConfig, error) {
 	if config.Id == "" {
 		return config, errors.New("no vault id found in ldconfig")
 	}
+	if err := keystore.CheckId(config.Id); err != nil {
+		return config, err
+	}
 
 	return config, nil
 }
@@ -31,6 +31,7 @@ import (
 	"strings"
 
 	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
 )
 
 type Manifest struct {
@@ -38,25 +39,31 @@ type Manifest struct {
 	path  string
 }
 
-func (m Manifest) Export() {
-	err := ioutil.WriteFile(m.path, m.serialize(), 0644)
-	log.FatalPanic(err)
+func (m Manifest) Export() error {
+	log.Debugf("writing %s", m.path)
+	return errors.Wrap(ioutil.WriteFile(m.path, m.Serialize(), 0644), "unable to write manifest")
 }
 
 func ImportManifest(ctx Context) (Manifest, error) {
-	m := Manifest{
-		Files: make([]Filemeta, 0, 32),
-		path:  filepath.Join(ctx.LockgitPath, "manifest"),
-	}
-	file, err := os.Open(m.path)
+	path := ctx.ManifestPath
+	data, err := ctx.ReadFile(path)
 	if os.IsNotExist(err) {
-		return m, nil
+		return Manifest{Files: make([]Filemeta, 0, 32), path: path}, nil
 	} else if err != nil {
-		return m, &ManifestLoadError{ctx.RelPath(m.path), err.Error()}
+		return Manifest{Files: make([]Filemeta, 0, 32), path: path}, &ManifestLoadError{ctx.RelPath(path), err.Error()}
+	}
+	return ParseManifest(ctx, data)
+}
+
+// Parse the contents of a manifest file.  The manifest does not need to come from the
+// working directory, for instance it may have been read from a git revision.
+func ParseManifest(ctx Context, data []byte) (Manifest, error) {
+	m := Manifest{
+		Files: make([]Filemeta, 0, 32),
+		path:  ctx.ManifestPath,
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		tokens := strings.SplitN(scanner.Text(), "\t", 2)
 		if len(tokens) != 2 {
@@ -68,7 +75,7 @@ func ImportManifest(ctx Context) (Manifest, error) {
 		}
 		m.Add(Filemeta{Id: sha, RelPath: tokens[1], AbsPath: filepath.Join(ctx.ProjectPath, tokens[1])})
 	}
-	err = scanner.Err()
+	err := scanner.Err()
 	if err != nil {
 		return m, &ManifestLoadError{ctx.RelPath(m.path), err.Error()}
 	}
@@ -89,7 +96,8 @@ func (m *Manifest) Add(filemeta Filemeta) {
 	m.sort()
 }
 
-func (m Manifest) serialize() []byte {
+// Returns the manifest in the format it is saved in
+func (m Manifest) Serialize() []byte {
 	m.sort()
 	var buffer bytes.Buffer
 	for _, v := range m.Files {
@@ -0,0 +1,211 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type op int
+
+const (
+	opEqual  op = 0
+	opDelete op = 1
+	opInsert op = 2
+)
+
+type edit struct {
+	op   op
+	line string
+}
+
+// Returns true if the data looks like it is binary rather than text
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// Produce a unified diff of two texts with the given number of lines of context.  The names are
+// used for the --- and +++ header lines.  An empty string is returned if the texts are the same.
+func Unified(oldName, newName string, a, b []byte, context int) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	edits := lineDiff(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(edits); {
+		// find the next change
+		for start < len(edits) && edits[start].op == opEqual {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+
+		// extend the hunk until there is a run of unchanged lines longer than twice the context
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].op != opEqual {
+				end = i + 1
+			} else if i-end >= 2*context {
+				break
+			}
+		}
+
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + context
+		if hunkEnd > len(edits) {
+			hunkEnd = len(edits)
+		}
+
+		oldLine, newLine := 1, 1
+		for _, e := range edits[:hunkStart] {
+			if e.op != opInsert {
+				oldLine++
+			}
+			if e.op != opDelete {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, e := range edits[hunkStart:hunkEnd] {
+			if e.op != opInsert {
+				oldCount++
+			}
+			if e.op != opDelete {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, e := range edits[hunkStart:hunkEnd] {
+			switch e.op {
+			case opEqual:
+				out.WriteString(" ")
+			case opDelete:
+				out.WriteString("-")
+			case opInsert:
+				out.WriteString("+")
+			}
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = hunkEnd
+	}
+	return out.String()
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line-1)
+	} else if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// Split text into lines, keeping the line endings
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Compute the shortest edit script between a and b using the Myers diff algorithm
+func lineDiff(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	trace := make([][]int, 0, 16)
+
+	done := false
+	for d := 0; d <= n+m && !done; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+	}
+
+	// walk backwards through the trace to recover the edits
+	edits := make([]edit, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{opInsert, b[y-1]})
+			} else {
+				edits = append(edits, edit{opDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
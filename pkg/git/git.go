@@ -0,0 +1,326 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A commit as reported by git log
+type Commit struct {
+	Hash    string
+	Parents []string
+	Author  string
+	Email   string
+	Date    time.Time
+	Subject string
+}
+
+func (c Commit) ShortHash() string {
+	if len(c.Hash) > 8 {
+		return c.Hash[:8]
+	}
+	return c.Hash
+}
+
+// Returned when git exits with a non-zero status
+type ExitError struct {
+	Command string
+	Code    int
+	Stderr  string
+}
+
+func (err *ExitError) Error() string {
+	msg := err.Stderr
+	if msg == "" {
+		msg = fmt.Sprintf("exit status %d", err.Code)
+	}
+	return fmt.Sprintf("git %s: %s", err.Command, msg)
+}
+
+// Run a git command in the given directory and return what it wrote to stdout.
+func Run(dir string, args ...string) ([]byte, error) {
+	return RunWithInput(dir, nil, args...)
+}
+
+// Run a git command with data provided on stdin
+func RunWithInput(dir string, input []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return stdout.Bytes(), &ExitError{args[0], exitErr.ExitCode(), strings.TrimSpace(stderr.String())}
+		}
+		return stdout.Bytes(), errors.Wrap(err, "unable to run git")
+	}
+	return stdout.Bytes(), nil
+}
+
+// Tests if dir is inside of a git work tree
+func IsRepo(dir string) bool {
+	out, err := Run(dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// Returns the commits which changed path, newest first.  The path is relative to dir.
+func Log(dir string, path string) ([]Commit, error) {
+	out, err := Run(dir, "log", "--format=%H%x1f%P%x1f%an%x1f%ae%x1f%aI%x1f%s", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]Commit, 0, 32)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 6 {
+			return nil, errors.Errorf("unexpected git log output: %s", line)
+		}
+		date, err := time.Parse(time.RFC3339, fields[4])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unexpected date in git log output")
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Parents: strings.Fields(fields[1]),
+			Author:  fields[2],
+			Email:   fields[3],
+			Date:    date,
+			Subject: fields[5],
+		})
+	}
+	return commits, nil
+}
+
+// Tests if path exists at the revision.  The path is relative to dir.
+func Exists(dir, rev, path string) bool {
+	_, err := Run(dir, "cat-file", "-e", objectName(rev, path))
+	return err == nil
+}
+
+// Returns the contents of path at the revision.  The path is relative to dir.
+func Show(dir, rev, path string) ([]byte, error) {
+	return Run(dir, "cat-file", "blob", objectName(rev, path))
+}
+
+func objectName(rev, path string) string {
+	return rev + ":./" + strings.TrimPrefix(filepath.ToSlash(path), "./")
+}
+
+// Returns the full hash of the commit a revision refers to
+func ResolveCommit(dir, rev string) (string, error) {
+	out, err := Run(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", errors.Errorf("unknown revision %s", rev)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// RevSource reads files from a git revision instead of the working directory
+type RevSource struct {
+	Dir string // project directory, which must be in a git work tree
+	Rev string
+}
+
+func (s RevSource) ReadFile(projRelPath string) ([]byte, error) {
+	if !Exists(s.Dir, s.Rev, projRelPath) {
+		return nil, &os.PathError{Op: "read", Path: s.Rev + ":" + projRelPath, Err: os.ErrNotExist}
+	}
+	return Show(s.Dir, s.Rev, projRelPath)
+}
+
+// Returns the path to the .git directory for the work tree containing dir
+func GitDir(dir string) (string, error) {
+	out, err := Run(dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Returns the top directory of the work tree containing dir, which is where git runs hooks from
+func TopLevel(dir string) (string, error) {
+	out, err := Run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(string(out))), nil
+}
+
+// Set a value in the repository's local git config
+func SetConfig(dir, key, value string) error {
+	_, err := Run(dir, "config", "--local", key, value)
+	return err
+}
+
+// Find the commit which added path, searching all refs.  The path is relative to dir.
+func FindAdded(dir, path string) (string, error) {
+	out, err := Run(dir, "log", "--all", "--diff-filter=A", "-1", "--format=%H", "--", path)
+	if err != nil {
+		return "", err
+	}
+	hash := strings.TrimSpace(string(out))
+	if hash == "" {
+		return "", &os.PathError{Op: "find", Path: path, Err: os.ErrNotExist}
+	}
+	return hash, nil
+}
+
+// Make sure a pattern in the .gitattributes file in dir has the attribute.  If the pattern is already
+// in the file, the attribute is added to its line, otherwise a new line is appended.  Returns true if
+// the file was changed.
+func AddAttribute(dir, pattern, attr string) (bool, error) {
+	path := filepath.Join(dir, ".gitattributes")
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	attrName := strings.SplitN(strings.TrimLeft(attr, "-!"), "=", 2)[0]
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	found := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != pattern {
+			continue
+		}
+		found = true
+		for j, field := range fields[1:] {
+			name := strings.SplitN(strings.TrimLeft(field, "-!"), "=", 2)[0]
+			if field == attr {
+				return false, nil
+			} else if name == attrName {
+				fields[j+1] = attr
+				lines[i] = strings.Join(fields, " ")
+				return true, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+			}
+		}
+		lines[i] = line + " " + attr
+		break
+	}
+	if !found {
+		lines = append(lines, pattern+" "+attr)
+	}
+	return true, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// Returns the files staged to be committed which are inside of dir, relative to dir
+func StagedFiles(dir string) ([]string, error) {
+	out, err := Run(dir, "diff", "--cached", "--name-only", "--relative", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, 16)
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, filepath.FromSlash(file))
+		}
+	}
+	return files, nil
+}
+
+// Returns the directory git runs hooks from, which respects core.hooksPath
+func HooksDir(dir string) (string, error) {
+	out, err := Run(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
+}
+
+// Returns the value of an attribute for each of the files.  The paths are relative to dir.
+func CheckAttr(dir, attr string, files []string) (map[string]string, error) {
+	values := make(map[string]string)
+	if len(files) == 0 {
+		return values, nil
+	}
+	out, err := Run(dir, append([]string{"check-attr", "-z", attr, "--"}, files...)...)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		values[filepath.FromSlash(fields[i])] = fields[i+2]
+	}
+	return values, nil
+}
+
+// Returns the files which are ignored by git.  The paths are relative to dir.
+func Ignored(dir string, files []string) ([]string, error) {
+	ignored := make([]string, 0)
+	if len(files) == 0 {
+		return ignored, nil
+	}
+	input := []byte(strings.Join(files, "\x00") + "\x00")
+	out, err := RunWithInput(dir, input, "check-ignore", "-z", "--stdin", "--no-index")
+	if exitErr, ok := err.(*ExitError); ok && exitErr.Code == 1 {
+		// none of the files are ignored
+		return ignored, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			ignored = append(ignored, filepath.FromSlash(file))
+		}
+	}
+	return ignored, nil
+}
+
+// Returns the files in the work tree which are tracked or are not ignored, relative to dir.  Tracked files
+// which have been deleted are included.
+func ListFiles(dir string) ([]string, error) {
+	out, err := Run(dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, 64)
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, filepath.FromSlash(file))
+		}
+	}
+	return files, nil
+}
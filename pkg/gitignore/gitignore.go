@@ -21,35 +21,125 @@
 package gitignore
 
 import (
-	"bufio"
-	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/pkg/errors"
 )
 
-// Add a path to the end of the .gitignore file if it is not currently in it.
-// Takes in the .gitingnore path and the files to add to it
-func Add(path string, line string) {
-	fullpath := filepath.Join(path, ".gitignore")
-	file, err := os.OpenFile(fullpath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+// The lines lockgit adds to a .gitignore file are kept between these markers, so they can be removed again
+// without touching lines written by hand
+const (
+	BlockBegin = "# BEGIN lockgit - entries between these lines are managed by lockgit"
+	BlockEnd   = "# END lockgit"
+)
 
-	log.FatalExit(err)
-	defer file.Close()
+// A .gitignore file split around the lockgit block
+type file struct {
+	before []string
+	block  []string
+	after  []string
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		text := scanner.Text()
-		if strings.TrimSpace(text) == line {
-			return
+// Add a line to the lockgit block of the .gitignore file in dir, creating the file or the block if needed.
+// Nothing is changed if the line is already anywhere in the file.
+func Add(dir string, line string) error {
+	f, err := readFile(dir)
+	if err != nil {
+		return err
+	}
+	for _, lines := range [][]string{f.before, f.block, f.after} {
+		if indexOf(lines, line) >= 0 {
+			return nil
 		}
 	}
+	f.block = append(f.block, line)
+	return f.write(dir)
+}
 
-	err = scanner.Err()
-	log.FatalPanic(err)
+// Remove a line from the lockgit block of the .gitignore file in dir.  Lines outside of the block, such as the
+// lines added by older versions of lockgit, are left alone.  The block is removed when it is empty.
+func Remove(dir string, line string) error {
+	f, err := readFile(dir)
+	if err != nil {
+		return err
+	}
+	i := indexOf(f.block, line)
+	if i < 0 {
+		return nil
+	}
+	f.block = append(f.block[:i], f.block[i+1:]...)
+	return f.write(dir)
+}
 
-	_, err = fmt.Fprintf(file, "\n%s", line)
-	log.FatalPanic(err)
+// Returns the lines in the lockgit block of the .gitignore file in dir
+func Managed(dir string) ([]string, error) {
+	f, err := readFile(dir)
+	return f.block, err
+}
+
+func readFile(dir string) (file, error) {
+	f := file{}
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return f, errors.Wrap(err, "unable to read .gitignore")
+	}
+	text := strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if text == "" {
+		return f, nil
+	}
+
+	section := &f.before
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == BlockBegin && section == &f.before {
+			section = &f.block
+		} else if trimmed == BlockEnd && section == &f.block {
+			section = &f.after
+		} else if section == &f.block {
+			if trimmed != "" {
+				f.block = append(f.block, trimmed)
+			}
+		} else {
+			*section = append(*section, line)
+		}
+	}
+	return f, nil
+}
+
+func (f file) write(dir string) error {
+	lines := append([]string(nil), f.before...)
+	for len(f.block) == 0 && len(lines) > 0 && lines[len(lines)-1] == "" {
+		// drop the blank line which separated the block from the lines before it
+		lines = lines[:len(lines)-1]
+	}
+	if len(f.block) > 0 {
+		if len(lines) > 0 && lines[len(lines)-1] != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, BlockBegin)
+		lines = append(lines, f.block...)
+		lines = append(lines, BlockEnd)
+	}
+	lines = append(lines, f.after...)
+
+	data := ""
+	if len(lines) > 0 {
+		data = strings.Join(lines, "\n") + "\n"
+	}
+	err := ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte(data), 0644)
+	return errors.Wrap(err, "unable to update .gitignore")
+}
+
+func indexOf(lines []string, line string) int {
+	for i, l := range lines {
+		if strings.TrimSpace(l) == line {
+			return i
+		}
+	}
+	return -1
 }
@@ -0,0 +1,220 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gitignore
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+)
+
+// A glob pattern with the semantics of a line in a .gitignore file at the root of a project
+type Pattern struct {
+	Negated bool   // the pattern started with !
+	DirOnly bool   // the pattern ended with a slash, so it only matches directories
+	Glob    string // a doublestar glob matching paths relative to the root
+}
+
+// Parse a line from a .gitignore file.  Patterns containing a slash, other than at the end, are anchored to the
+// root.  Other patterns match a file or directory name at any depth.
+func ParsePattern(line string) Pattern {
+	p := Pattern{}
+	if strings.HasPrefix(line, "!") {
+		p.Negated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if !anchored && !strings.HasPrefix(line, "**") {
+		line = "**/" + line
+	}
+	p.Glob = line
+	return p
+}
+
+// True if a path relative to the root matches the pattern, or is inside a directory which matches it
+func (p Pattern) Match(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	if (isDir || !p.DirOnly) && p.match(relPath) {
+		return true
+	}
+	for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if p.match(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Pattern) match(relPath string) bool {
+	match, err := doublestar.Match(p.Glob, relPath)
+	return err == nil && match
+}
+
+// Returns the first pattern which matches a path relative to the root, or "" if none match or the path is
+// excluded by a later negated pattern.  Unlike git, a negated pattern can exclude a file inside a directory
+// matched by an earlier pattern.
+func MatchPatterns(relPath string, isDir bool, patterns []string) string {
+	return matchParsed(relPath, isDir, patterns, parsePatterns(patterns))
+}
+
+func parsePatterns(patterns []string) []Pattern {
+	parsed := make([]Pattern, len(patterns))
+	for i, pattern := range patterns {
+		parsed[i] = ParsePattern(pattern)
+	}
+	return parsed
+}
+
+// Like MatchPatterns, with each pattern already parsed
+func matchParsed(relPath string, isDir bool, patterns []string, parsed []Pattern) string {
+	first, included := "", false
+	for i, p := range parsed {
+		if !p.Match(relPath, isDir) {
+			continue
+		}
+		included = !p.Negated
+		if included && first == "" {
+			first = patterns[i]
+		}
+	}
+	if !included {
+		return ""
+	}
+	return first
+}
+
+// True if a file inside the directory could match the pattern, because the directory matches it or the
+// directory is a prefix of paths the glob can match.  This errs on the side of true.
+func (p Pattern) mayMatchInside(relDir string) bool {
+	if p.Match(relDir, true) {
+		return true
+	}
+	if strings.Contains(p.Glob, "{") {
+		// alternatives can contain slashes, so the glob cannot be split into path segments
+		return true
+	}
+	globParts := strings.Split(p.Glob, "/")
+	dirParts := strings.Split(filepath.ToSlash(relDir), "/")
+	for i, dirPart := range dirParts {
+		if i >= len(globParts) {
+			return false
+		}
+		if strings.Contains(globParts[i], "**") {
+			return true
+		}
+		if match, err := doublestar.Match(globParts[i], dirPart); err != nil || !match {
+			return err != nil
+		}
+	}
+	return len(globParts) > len(dirParts)
+}
+
+// True if no file inside the directory can be matched by the patterns.  That is the case when each pattern
+// which may match inside it is followed by a negated pattern which excludes the whole directory.
+func pruneDir(relDir string, parsed []Pattern) bool {
+	for i, p := range parsed {
+		if p.Negated || !p.mayMatchInside(relDir) {
+			continue
+		}
+		excluded := false
+		for _, later := range parsed[i+1:] {
+			if later.Negated && later.Match(relDir, true) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the absolute paths of the files under the root which are matched by the patterns.  The .git and
+// .lockgit directories are skipped, as are directories which cannot contain a match.
+func Files(root string, patterns []string) ([]string, error) {
+	files := make([]string, 0, 64)
+	if len(patterns) == 0 {
+		return files, nil
+	}
+	parsed := parsePatterns(patterns)
+	err := filepath.Walk(root, func(absPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if absPath == root {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, absPath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if name := info.Name(); name == ".git" || name == ".lockgit" || pruneDir(relPath, parsed) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchParsed(relPath, false, patterns, parsed) != "" {
+			files = append(files, absPath)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Convert a pattern from a .gitignore file in dir, which is relative to the root, to the pattern with the same
+// meaning in a .gitignore file at the root
+func Rebase(pattern, dir string) string {
+	dir = filepath.ToSlash(dir)
+	if dir == "" || dir == "." {
+		return pattern
+	}
+	negation := ""
+	if strings.HasPrefix(pattern, "!") {
+		negation = "!"
+		pattern = pattern[1:]
+	}
+	if strings.Contains(strings.TrimRight(pattern, "/"), "/") {
+		return negation + dir + "/" + strings.TrimPrefix(pattern, "/")
+	}
+	return negation + dir + "/**/" + pattern
+}
+
+// Returns a doublestar glob for a pattern, ignoring any negation, for tools which do not support the
+// .gitignore syntax
+func ToGlob(pattern string) string {
+	p := ParsePattern(pattern)
+	if p.DirOnly {
+		return p.Glob + "/**"
+	}
+	return p.Glob
+}
@@ -0,0 +1,157 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Keeps the key of each vault in its own file in a directory, named after the id of the vault.  The directory
+// can be kept somewhere safer than the home directory, such as an encrypted volume, without moving the rest of
+// the config file.
+type Dir struct {
+	path string
+}
+
+// The contents of the file for one vault
+type dirFile struct {
+	Path     string            `yaml:"path,omitempty"`
+	Key      string            `yaml:"key,omitempty"`
+	Profiles map[string]string `yaml:"profiles,omitempty"` // the keys of the profiles
+}
+
+func NewDir(path string) *Dir {
+	return &Dir{path: path}
+}
+
+func (d *Dir) Get(id, profile string) (Entry, error) {
+	file, err := d.read(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Id: id, Profile: profile, Key: file.Key, Path: file.Path}
+	if profile != "" {
+		entry.Key = file.Profiles[profile]
+	}
+	return entry, nil
+}
+
+func (d *Dir) Set(entry Entry) error {
+	file, err := d.read(entry.Id)
+	if err != nil {
+		return err
+	}
+	if entry.Profile == "" {
+		file.Key = entry.Key
+		file.Path = entry.Path
+	} else {
+		if file.Profiles == nil {
+			file.Profiles = make(map[string]string)
+		}
+		file.Profiles[entry.Profile] = entry.Key
+	}
+	return d.write(entry.Id, file)
+}
+
+func (d *Dir) Delete(id, profile string) error {
+	if err := CheckId(id); err != nil {
+		return err
+	}
+	if profile == "" {
+		err := os.Remove(d.filePath(id))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	file, err := d.read(id)
+	if err != nil {
+		return err
+	}
+	delete(file.Profiles, profile)
+	return d.write(id, file)
+}
+
+func (d *Dir) List() ([]Entry, error) {
+	names, err := filepath.Glob(filepath.Join(d.path, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), ".yml")
+		file, err := d.read(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Id: id, Key: file.Key, Path: file.Path})
+		for profile, key := range file.Profiles {
+			entries = append(entries, Entry{Id: id, Profile: profile, Key: key})
+		}
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (d *Dir) String() string {
+	return d.path
+}
+
+// Returns the file for a vault.  The id must be checked with CheckId first.
+func (d *Dir) filePath(id string) string {
+	return filepath.Join(d.path, id+".yml")
+}
+
+func (d *Dir) read(id string) (dirFile, error) {
+	file := dirFile{}
+	if err := CheckId(id); err != nil {
+		return file, err
+	}
+	data, err := ioutil.ReadFile(d.filePath(id))
+	if os.IsNotExist(err) {
+		return file, nil
+	} else if err != nil {
+		return file, err
+	}
+	err = yaml.Unmarshal(data, &file)
+	return file, errors.Wrapf(err, "unable to read %s", d.filePath(id))
+}
+
+// The keys are only readable by the owner
+func (d *Dir) write(id string, file dirFile) error {
+	if err := CheckId(id); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.path, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.filePath(id), data, 0600)
+}
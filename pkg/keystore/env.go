@@ -0,0 +1,97 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package keystore
+
+import (
+	"os"
+	"strings"
+)
+
+// The variable holding the key of the default profile of any vault which does not have its own variable
+const EnvKey = "LOCKGIT_KEY"
+
+// Reads the keys from environment variables, for CI and containers where the config file is not available.  The
+// key of a vault is read from LOCKGIT_KEY_<ID>, where <ID> is the id of the vault in upper case with '-' replaced
+// by '_', and the key of one of its profiles from LOCKGIT_KEY_<ID>__<PROFILE>.  If a vault does not have its own
+// variable, LOCKGIT_KEY is used for its default profile.  The environment cannot be changed, so Set and Delete
+// return a ReadOnlyError.
+type Env struct{}
+
+func NewEnv() *Env {
+	return &Env{}
+}
+
+func (e *Env) Get(id, profile string) (Entry, error) {
+	entry := Entry{Id: id, Profile: profile, Key: os.Getenv(envVar(id, profile))}
+	if entry.Key == "" && profile == "" {
+		entry.Key = os.Getenv(EnvKey)
+	}
+	return entry, nil
+}
+
+func (e *Env) Set(entry Entry) error {
+	return &ReadOnlyError{e}
+}
+
+func (e *Env) Delete(id, profile string) error {
+	return &ReadOnlyError{e}
+}
+
+// Only the vaults with their own variables are listed.  Their ids are in lower case, like the ids lockgit makes.
+func (e *Env) List() ([]Entry, error) {
+	entries := make([]Entry, 0)
+	for _, variable := range os.Environ() {
+		parts := strings.SplitN(variable, "=", 2)
+		if !strings.HasPrefix(parts[0], EnvKey+"_") || len(parts) < 2 || parts[1] == "" {
+			continue
+		}
+		names := strings.SplitN(strings.TrimPrefix(parts[0], EnvKey+"_"), "__", 2)
+		entry := Entry{Id: strings.ToLower(strings.ReplaceAll(names[0], "_", "-")), Key: parts[1]}
+		if len(names) > 1 {
+			entry.Profile = strings.ToLower(names[1])
+		}
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (e *Env) String() string {
+	return "the environment"
+}
+
+// The name of the variable for the key of a vault or profile
+func envVar(id, profile string) string {
+	name := EnvKey + "_" + envName(id)
+	if profile != "" {
+		name += "__" + envName(profile)
+	}
+	return name
+}
+
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(s))
+}
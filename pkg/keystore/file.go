@@ -0,0 +1,157 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package keystore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// Keeps the keys in a YAML file, under vaults.<id>.key and vaults.<id>.profiles.<name>.key.  This is the lockgit
+// config file unless another key store is chosen.  The file is read again for every operation, so changes made by
+// other processes are seen.
+type File struct {
+	path string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Get(id, profile string) (Entry, error) {
+	v, err := f.read()
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Id:      id,
+		Profile: profile,
+		Key:     v.GetString(fileSetting(id, profile) + ".key"),
+		Path:    v.GetString(fileSetting(id, "") + ".path"),
+	}, nil
+}
+
+func (f *File) Set(entry Entry) error {
+	v, err := f.read()
+	if err != nil {
+		return err
+	}
+	v.Set(fileSetting(entry.Id, entry.Profile)+".key", entry.Key)
+	if entry.Profile == "" {
+		v.Set(fileSetting(entry.Id, "")+".path", entry.Path)
+	}
+	return v.WriteConfig()
+}
+
+// Viper cannot unset a key, so the file is edited directly
+func (f *File) Delete(id, profile string) error {
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	settings := yaml.MapSlice{}
+	err = yaml.Unmarshal(data, &settings)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", f.path)
+	}
+	settings = removeSetting(settings, strings.Split(fileSetting(id, profile), "."))
+
+	data, err = yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.path, data, info.Mode().Perm())
+}
+
+func (f *File) List() ([]Entry, error) {
+	v, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0)
+	for id := range v.GetStringMap("vaults") {
+		entries = append(entries, Entry{
+			Id:   id,
+			Key:  v.GetString(fileSetting(id, "") + ".key"),
+			Path: v.GetString(fileSetting(id, "") + ".path"),
+		})
+		for profile := range v.GetStringMap(fileSetting(id, "") + ".profiles") {
+			entries = append(entries, Entry{
+				Id:      id,
+				Profile: profile,
+				Key:     v.GetString(fileSetting(id, profile) + ".key"),
+			})
+		}
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (f *File) String() string {
+	return f.path
+}
+
+func (f *File) read() (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(f.path)
+	err := v.ReadInConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "unable to read %s", f.path)
+	}
+	return v, nil
+}
+
+// The setting holding a vault or one of its profiles
+func fileSetting(id, profile string) string {
+	if profile != "" {
+		return fmt.Sprintf("vaults.%s.profiles.%s", id, profile)
+	}
+	return "vaults." + id
+}
+
+// Remove a nested setting.  Viper lowercases the settings it writes, so the keys are not case sensitive.
+func removeSetting(settings yaml.MapSlice, keys []string) yaml.MapSlice {
+	kept := yaml.MapSlice{}
+	for _, item := range settings {
+		if !strings.EqualFold(fmt.Sprint(item.Key), keys[0]) {
+			kept = append(kept, item)
+		} else if len(keys) > 1 {
+			if nested, ok := item.Value.(yaml.MapSlice); ok {
+				item.Value = removeSetting(nested, keys[1:])
+			}
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,134 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package keystore saves the keys of lockgit vaults, and the paths where the vaults were last seen.
+package keystore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/jswidler/lockgit/pkg/log"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// What a key store saves for a vault, or for one of its profiles
+type Entry struct {
+	Id      string // the id of the vault
+	Profile string // the name of the profile, or "" for the vault's default profile
+	Key     string // the base32 encoded key, or "" if the key is not saved
+	Path    string // the last known location of the vault.  Only saved for the default profile.
+}
+
+// A place to save the keys of vaults.  Get returns an entry without a key or path when nothing is saved for
+// the vault or profile, and only returns an error if the store could not be read.
+type KeyStore interface {
+	Get(id, profile string) (Entry, error)
+	Set(entry Entry) error
+	// Delete the entry for a profile.  Deleting the default profile deletes the vault and all of its profiles.
+	Delete(id, profile string) error
+	// Returns the entries for every vault and profile, sorted by id and then profile
+	List() ([]Entry, error)
+	// Describes where the keys are kept, in messages
+	String() string
+}
+
+var idRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Returns an error if a vault id cannot be used by the key stores.  The id is read from the vault, which may
+// come from an untrusted repository, and some key stores use it in file names.
+func CheckId(id string) error {
+	if !idRegexp.MatchString(id) {
+		return fmt.Errorf("invalid vault id '%s': use letters, numbers, '_' and '-'", id)
+	}
+	return nil
+}
+
+// Returned when changing a key store which can only be read, such as the environment
+type ReadOnlyError struct {
+	store KeyStore
+}
+
+func (err *ReadOnlyError) Error() string {
+	return fmt.Sprintf("cannot save keys to %s", err.store)
+}
+
+// The settings in the lockgit config file which choose the key store
+type config struct {
+	KeyStore string `yaml:"keystore"`     // file, dir, env or memory.  The default is file.
+	Dir      string `yaml:"keystore-dir"` // the directory of the dir key store
+}
+
+// Returns the key store chosen by a lockgit config file.  If path is empty, ~/.lockgit.yml is used.  The default
+// is to keep the keys in the config file itself.
+func FromConfig(path string) (KeyStore, error) {
+	if path == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".lockgit.yml")
+	}
+
+	settings := config{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", path)
+	}
+
+	log.Debugf("choosing the key store from %s", path)
+	switch settings.KeyStore {
+	case "", "file":
+		return NewFile(path), nil
+	case "dir":
+		dir := settings.Dir
+		if dir == "" {
+			dir = filepath.Join(filepath.Dir(path), ".lockgit", "keys")
+		}
+		dir, err = homedir.Expand(dir)
+		if err != nil {
+			return nil, err
+		}
+		return NewDir(dir), nil
+	case "env":
+		return NewEnv(), nil
+	case "memory":
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("unknown keystore '%s' in %s: use file, dir, env or memory", settings.KeyStore, path)
+}
+
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Id == entries[j].Id {
+			return entries[i].Profile < entries[j].Profile
+		}
+		return entries[i].Id < entries[j].Id
+	})
+}
@@ -0,0 +1,83 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package keystore
+
+import (
+	"sync"
+)
+
+// Keeps the keys in memory, for tests and programs which manage keys themselves.  Nothing is saved when the
+// process exits.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[Entry]Entry // keyed by the id and profile of the entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[Entry]Entry)}
+}
+
+func (m *Memory) Get(id, profile string) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[Entry{Id: id, Profile: profile}]
+	if !ok {
+		entry = Entry{Id: id, Profile: profile}
+	}
+	entry.Path = m.entries[Entry{Id: id}].Path
+	return entry, nil
+}
+
+func (m *Memory) Set(entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry.Profile != "" {
+		entry.Path = ""
+	}
+	m.entries[Entry{Id: entry.Id, Profile: entry.Profile}] = entry
+	return nil
+}
+
+func (m *Memory) Delete(id, profile string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.entries {
+		if k.Id == id && (profile == "" || k.Profile == profile) {
+			delete(m.entries, k)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) List() ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]Entry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (m *Memory) String() string {
+	return "memory"
+}
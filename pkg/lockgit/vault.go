@@ -0,0 +1,146 @@
+// Copyright © 2018 Jesse Swidler <jswidler@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package lockgit is the Go API for lockgit vaults.  The lockgit command line is built on it, but unlike the
+// command line, it never exits the process or asks for input: every problem is returned as an error.
+//
+// The keys of the vaults are read from the key store chosen by ~/.lockgit.yml, unless another key store is
+// given in the options.
+package lockgit
+
+import (
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/keystore"
+)
+
+// The results of the vault methods
+type (
+	FileChange   = app.FileChange
+	Action       = app.Action
+	StatusRecord = app.StatusRecord
+	FileState    = app.FileState
+)
+
+// The errors returned by the vault methods.  Each has an ExitCode method with the exit code the command
+// line would use for it.
+type (
+	PartialError         = app.PartialError
+	KeyLoadError         = content.KeyLoadError
+	ManifestLoadError    = content.ManifestLoadError
+	VaultNotFoundError   = content.VaultNotFoundError
+	ProfileNotFoundError = content.ProfileNotFoundError
+)
+
+// Settings for the vault methods.  These are the same as the global flags of the command line.
+type Options struct {
+	Profile           string   // use one of the vault's profiles instead of the default profile
+	Force             bool     // overwrite files in the vault and plaintext files which have changed
+	DryRun            bool     // report what would change without changing any files
+	NoUpdateGitignore bool     // do not add the secrets to .gitignore
+	Exclude           []string // skip files matching these patterns
+	Tags              []string // only use secrets which have all of these tags
+	Rev               string   // open the secrets from a git revision instead of the working directory
+	RotateEvery       string   // how often secrets added to the vault should be rotated, such as 90d
+	Jobs              int      // how many files to encrypt or decrypt at once, GOMAXPROCS if 0
+
+	KeyStore keystore.KeyStore // where the keys are saved, such as a keystore.Memory
+}
+
+// A lockgit vault.  Paths given to its methods are relative to the directory the vault was opened from.
+type Vault struct {
+	wd          string
+	projectPath string
+	opts        Options
+}
+
+// Open the vault in path or the closest of its parent directories.  Returns a VaultNotFoundError if there
+// is no vault.  The key is not required to open the vault, but methods which decrypt or encrypt secrets return
+// a KeyLoadError without it.
+func Open(path string, opts Options) (*Vault, error) {
+	if opts.KeyStore == nil {
+		store, err := keystore.FromConfig("")
+		if err != nil {
+			return nil, err
+		}
+		opts.KeyStore = store
+	}
+	ctx, err := content.FromProfile(path, opts.Profile, opts.KeyStore)
+	if err != nil && !content.IsKeyLoadError(err) {
+		return nil, err
+	}
+	return &Vault{wd: ctx.WorkingPath, projectPath: ctx.ProjectPath, opts: opts}, nil
+}
+
+// The directory which contains the vault's .lockgit directory
+func (v *Vault) Path() string {
+	return v.projectPath
+}
+
+// Add files and glob patterns to the vault
+func (v *Vault) Add(patterns ...string) ([]FileChange, error) {
+	return app.AddToVault(v.appOptions(), patterns)
+}
+
+// Remove files and glob patterns from the vault.  The plaintext files are not deleted.
+func (v *Vault) Remove(patterns ...string) ([]FileChange, error) {
+	return app.RemoveFromVault(v.appOptions(), patterns)
+}
+
+// Move a secret, or the secrets in a directory, to a new path.  The plaintext files are moved too.
+func (v *Vault) Move(source, dest string) ([]FileChange, error) {
+	return app.Move(v.appOptions(), source, dest)
+}
+
+// Save the changes to the plaintext secrets in the vault, and add new files matching the glob patterns
+func (v *Vault) Commit() ([]FileChange, error) {
+	return app.Commit(v.appOptions())
+}
+
+// Decrypt the secrets matching one of the paths or glob patterns, or every secret if there are no paths
+func (v *Vault) OpenFiles(paths ...string) ([]FileChange, error) {
+	return app.OpenVault(v.appOptions(), paths)
+}
+
+// Delete the plaintext secrets matching one of the paths or glob patterns, or every secret if there are no paths
+func (v *Vault) CloseFiles(paths ...string) ([]FileChange, error) {
+	return app.CloseVault(v.appOptions(), paths)
+}
+
+// Returns the status of each secret, and of each file matched by the glob patterns, sorted by path
+func (v *Vault) Status() ([]StatusRecord, error) {
+	return app.Status(v.appOptions())
+}
+
+func (v *Vault) appOptions() app.Options {
+	return app.Options{
+		Wd:                v.wd,
+		NoUpdateGitignore: v.opts.NoUpdateGitignore,
+		Force:             v.opts.Force,
+		Rev:               v.opts.Rev,
+		Exclude:           v.opts.Exclude,
+		DryRun:            v.opts.DryRun,
+		Tags:              v.opts.Tags,
+		RotateEvery:       v.opts.RotateEvery,
+		Profile:           v.opts.Profile,
+		Jobs:              v.opts.Jobs,
+		KeyStore:          v.opts.KeyStore,
+	}
+}
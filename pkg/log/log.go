@@ -1,37 +1,168 @@
 package log
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 )
 
-//Log checks and logs a error
+// Exit codes used by lockgit
+const (
+	ExitOk            = 0 // success, or the vault is clean
+	ExitError         = 1 // the command failed
+	ExitDirty         = 2 // status --check found secrets which are changed or not in the vault
+	ExitPartial       = 3 // the command failed for some files but not others
+	ExitKeyMissing    = 4 // the key for the vault could not be loaded
+	ExitVaultNotFound = 5 // there is no vault in the working directory or its parents
+)
+
+// Errors which implement ExitCoder choose the exit code used by FatalExit
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// How much is logged.  Each level includes the messages of the levels before it.
+type Level int
+
+const (
+	LevelQuiet   Level = iota // only errors
+	LevelNormal               // what a command changed, and warnings
+	LevelVerbose              // files which were skipped or watched
+	LevelDebug                // where keys were read from and every file read or written
+)
+
+var levelNames = []string{"quiet", "normal", "verbose", "debug"}
+
+func (l Level) String() string {
+	if l < LevelQuiet || l > LevelDebug {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// Parse a level from its name, as used by LOCKGIT_LOG
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelNormal, errors.Errorf("unknown log level '%s': expected quiet, normal, verbose or debug", name)
+}
+
+var level = LevelNormal
+
+// Set how much is logged
+func SetLevel(l Level) {
+	level = l
+}
+
+func GetLevel() Level {
+	return level
+}
+
+// The format of the output of a command
+type Format string
+
+const (
+	FormatText Format = "text" // messages are logged as the command runs
+	FormatJson Format = "json"
+	FormatYaml Format = "yaml"
+)
+
+// With JSON or YAML, stdout is reserved for the result of a command and errors are written to stderr in the
+// same format.  Only debug messages are still logged, to stderr.
+var outputFormat = FormatText
+
+// Set the format of the output
+func SetFormat(f Format) {
+	outputFormat = f
+}
+
+// LogError logs an error to stderr, if there is one
 func LogError(err error) {
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
+		printError(err)
 	}
 }
 
-// print something to stdout at normal verbosity
+// Print what a command did to stdout at normal verbosity
 func Info(message string) {
-	fmt.Println(message)
+	logf(LevelNormal, os.Stdout, "%s", message)
 }
 
 func Infof(format string, a ...interface{}) {
-	fmt.Fprintf(os.Stdout, format+"\n", a...)
+	logf(LevelNormal, os.Stdout, format, a...)
 }
 
-// for now just print it
+// Print a problem which did not stop the command to stderr at normal verbosity
+func Warn(message string) {
+	logf(LevelNormal, os.Stderr, "%s", message)
+}
+
+func Warnf(format string, a ...interface{}) {
+	logf(LevelNormal, os.Stderr, format, a...)
+}
+
+// Print details of what a command did to stdout with --verbose
 func Verbose(message string) {
-	fmt.Println(message)
+	logf(LevelVerbose, os.Stdout, "%s", message)
+}
+
+func Verbosef(format string, a ...interface{}) {
+	logf(LevelVerbose, os.Stdout, format, a...)
+}
+
+// Trace what lockgit is doing to stderr with --debug.  Never include the contents of a secret or a key.
+func Debugf(format string, a ...interface{}) {
+	if level >= LevelDebug {
+		fmt.Fprintf(os.Stderr, "debug: "+format+"\n", a...)
+	}
+}
+
+func logf(min Level, w io.Writer, format string, a ...interface{}) {
+	if level >= min && outputFormat == FormatText {
+		fmt.Fprintf(w, format+"\n", a...)
+	}
 }
 
 func FatalExit(err error) {
 	if err != nil {
+		printError(err)
+		os.Exit(ExitCodeOf(err))
+	}
+}
+
+// The exit code for an error: the code chosen by the first ExitCoder it wraps, or ExitError
+func ExitCodeOf(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return ExitError
+}
+
+func printError(err error) {
+	message := struct {
+		Error string `json:"error" yaml:"error"`
+	}{err.Error()}
+	switch outputFormat {
+	case FormatJson:
+		out, _ := json.Marshal(message)
+		fmt.Fprintf(os.Stderr, "%s\n", out)
+	case FormatYaml:
+		out, _ := yaml.Marshal(message)
+		fmt.Fprint(os.Stderr, string(out))
+	default:
 		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
 	}
 }
 
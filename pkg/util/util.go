@@ -21,7 +21,12 @@
 package util
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bmatcuk/doublestar"
 )
@@ -98,3 +103,94 @@ func Filter(vs []string, f func(string) bool) []string {
 	}
 	return vsf
 }
+
+// Returns the strings in vs without duplicates, in the order they first appear
+func Unique(vs []string) []string {
+	seen := make(map[string]bool)
+	return Filter(vs, func(v string) bool {
+		if seen[v] {
+			return false
+		}
+		seen[v] = true
+		return true
+	})
+}
+
+// True if a saved glob pattern excludes files instead of including them
+func IsNegated(pattern string) bool {
+	return strings.HasPrefix(pattern, "!")
+}
+
+// Expand saved glob patterns relative to the base path, in order.  Files matching a pattern are included, and
+// files matching a negated pattern, which starts with !, are excluded again like they are in a .gitignore file.
+func GetPatternFiles(base string, patterns []string) ([]string, error) {
+	files := make([]string, 0, 64)
+	for _, pattern := range patterns {
+		if IsNegated(pattern) {
+			absPattern := filepath.Join(base, pattern[1:])
+			var err error
+			files = Filter(files, func(path string) bool {
+				match, matchErr := doublestar.Match(absPattern, path)
+				if matchErr != nil {
+					err = matchErr
+				}
+				return !match
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		_, matches, _, err := GetFiles(filepath.Join(base, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	// a file can match more than one pattern
+	return Unique(files), nil
+}
+
+// Returns the first saved pattern which matches the path, or "" if none match or the path is excluded by a
+// later negated pattern.  The path and patterns must be relative to the same directory.
+func MatchPatterns(path string, patterns []string) (string, error) {
+	first, included := "", false
+	for _, pattern := range patterns {
+		negated := IsNegated(pattern)
+		glob := strings.TrimPrefix(pattern, "!")
+		match, err := doublestar.Match(glob, path)
+		if err != nil {
+			return "", err
+		}
+		if !match {
+			continue
+		}
+		included = !negated
+		if included && first == "" {
+			first = pattern
+		}
+	}
+	if !included {
+		return "", nil
+	}
+	return first, nil
+}
+
+// Parse a duration like time.ParseDuration, but also accept a whole number of days or weeks such as 90d or 2w
+func ParseDuration(s string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid duration '%s'", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration '%s': use a number of days such as 90d", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/cmd"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestNoDuplicateFlags(t *testing.T) {
+	var check func(c *cobra.Command)
+	check = func(c *cobra.Command) {
+		// a local flag with the same name as a persistent flag of a parent silently hides it
+		for p := c.Parent(); p != nil; p = p.Parent() {
+			p.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+				if local := c.Flags().Lookup(f.Name); local != nil && local != f {
+					t.Errorf("%s: flag --%s hides the flag of %s", c.CommandPath(), f.Name, p.CommandPath())
+				}
+				if f.Shorthand == "" {
+					return
+				}
+				if local := c.Flags().ShorthandLookup(f.Shorthand); local != nil && local != f {
+					t.Errorf("%s: flag -%s hides the flag of %s", c.CommandPath(), f.Shorthand, p.CommandPath())
+				}
+			})
+		}
+		for _, sub := range c.Commands() {
+			check(sub)
+		}
+	}
+	check(cmd.Command())
+}
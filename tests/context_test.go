@@ -19,19 +19,17 @@ func TestMultipleLockgits(t *testing.T) {
 	setupVault(t, group2Opts)
 	group2file := createFile(group2Opts, "group2file")
 
-	reloadConfig(baseOpts)
-	err := app.AddToVault(baseOpts, basefile)
+	_, err := app.AddToVault(baseOpts, basefile)
 	if err != nil {
 		t.Errorf("expected to add basefile to base vault: %s", err)
 	}
 
-	err = app.AddToVault(baseOpts, group1file)
+	_, err = app.AddToVault(baseOpts, group1file)
 	if err == nil {
 		t.Error("expected to fail to add group1file to base vault")
 	}
 
-	reloadConfig(group1Opts)
-	err = app.AddToVault(group1Opts, group2file)
+	_, err = app.AddToVault(group1Opts, group2file)
 	if err == nil {
 		t.Error("expected to fail to add group2file to group1 vault")
 	}
@@ -43,7 +41,7 @@ func TestAddLockgitFile(t *testing.T) {
 
 	keypath := filepath.Join(opts.Wd, ".lockgit", "ldconfig")
 
-	err := app.AddToVault(opts, []string{keypath})
+	_, err := app.AddToVault(opts, []string{keypath})
 	if err == nil {
 		t.Error("expected to fail to add key file to vault")
 	}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+)
+
+func TestDryRun(t *testing.T) {
+	opts := opts("dryruntest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+	_, _ = app.AddToVault(opts, []string{"dir1/file*"})
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
+
+	dryOpts := opts
+	dryOpts.DryRun = true
+	before := snapshot(t, opts.Wd)
+
+	var changes []app.FileChange
+	var err error
+	out := captureStdout(t, func() { changes, err = app.AddToVault(dryOpts, []string{"dir2/**"}) })
+	if err != nil || len(changes) != 7 {
+		t.Errorf("expected add to report 6 files and a pattern, got %v: %s", changes, err)
+	}
+	expectDryRunOutput(t, out, "would add file 'dir2/filea2' to vault", "would add glob pattern 'dir2/**' to vault")
+	out = captureStdout(t, func() { changes, err = app.Commit(dryOpts) })
+	if err != nil || len(changes) != 1 || changes[0].Action != app.ActionUpdated {
+		t.Errorf("expected commit to report filea1 updated, got %v: %s", changes, err)
+	}
+	expectDryRunOutput(t, out, "would update 'dir1/filea1'")
+	changes, err = app.CloseVault(dryOpts, []string{"dir1/fileb1"})
+	if err != nil || len(changes) != 1 || changes[0].Action != app.ActionClosed {
+		t.Errorf("expected close to report fileb1 closed, got %v: %s", changes, err)
+	}
+	_ = os.Remove(filepath.Join(opts.Wd, "dir1", "fileb1"))
+	// saved secrets are only logged with --verbose
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.LevelVerbose)
+	out = captureStdout(t, func() { changes, err = app.OpenVault(dryOpts, nil) })
+	if err != nil || len(changes) != 2 {
+		t.Errorf("expected open to report 2 files, got %v: %s", changes, err)
+	}
+	expectDryRunOutput(t, out, "would save secret to dir1/fileb1")
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "fileb1"), []byte(data1), 0644)
+	out = captureStdout(t, func() { changes, _ = app.RemoveFromVault(dryOpts, []string{"dir1/file*"}) })
+	if len(changes) != 3 {
+		t.Errorf("expected rm to report 2 files and a pattern, got %v", changes)
+	}
+	expectDryRunOutput(t, out, "would remove file 'dir1/filea1' from vault", "would remove glob pattern 'dir1/file*' from vault")
+
+	after := snapshot(t, opts.Wd)
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("expected dry run not to change any files")
+	}
+}
+
+// Checks that a dry run logged what it would do, and did not log anything as done
+func expectDryRunOutput(t *testing.T, out string, messages ...string) {
+	for _, message := range messages {
+		if !strings.Contains(out, message) {
+			t.Errorf("expected the dry run to log %q, got %q", message, out)
+		}
+	}
+	for _, done := range []string{"added", "updated", "removed", "saved"} {
+		if strings.Contains(out, done) {
+			t.Errorf("expected the dry run not to log changes as done, got %q", out)
+		}
+	}
+}
+
+// Returns the contents of every file in a directory
+func snapshot(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		files[path] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to read %s: %s", dir, err)
+	}
+	return files
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestDue(t *testing.T) {
+	opts := opts("duetest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+
+	rotateOpts := opts
+	rotateOpts.RotateEvery = "1ns"
+	_, _ = app.AddToVault(rotateOpts, []string{"filea"})
+	rotateOpts.RotateEvery = "90d"
+	_, _ = app.AddToVault(rotateOpts, []string{"fileb"})
+	_, _ = app.AddToVault(opts, []string{"dir1/file*"})
+
+	expired := time.Now().AddDate(0, 0, -1)
+	expiring := time.Now().AddDate(0, 0, 10)
+	later := time.Now().AddDate(1, 0, 0)
+	_, _ = app.Annotate(opts, []string{"dir1/filea1"}, app.Annotation{Expires: &expired})
+	_, _ = app.Annotate(opts, []string{"dir1/fileb1"}, app.Annotation{Expires: &expiring})
+
+	records, err := app.Due(opts, 30*24*time.Hour)
+	if _, ok := err.(*app.DueError); !ok || len(records) != 3 {
+		t.Fatalf("expected 3 secrets to be due, got %v: %s", records, err)
+	}
+	expected := map[string]app.DueReason{"dir1/filea1": app.DueExpired, "filea": app.DueRotationOverdue, "dir1/fileb1": app.DueExpiring}
+	for _, record := range records {
+		if expected[record.Path] != record.Reason {
+			t.Errorf("expected %s to be %s, got %s", record.Path, expected[record.Path], record.Reason)
+		}
+	}
+
+	_, _ = app.Annotate(opts, []string{"dir1/filea1", "dir1/fileb1"}, app.Annotation{Expires: &later})
+	rotate := ""
+	_, _ = app.Annotate(opts, []string{"filea"}, app.Annotation{RotateEvery: &rotate})
+	records, err = app.Due(opts, 30*24*time.Hour)
+	if err != nil || len(records) != 0 {
+		t.Errorf("expected no secrets to be due, got %v: %s", records, err)
+	}
+	records, _ = app.Due(opts, 100*24*time.Hour)
+	if len(records) != 1 || records[0].Path != "fileb" || records[0].Reason != app.DueRotation {
+		t.Errorf("expected fileb to be due for rotation within 100 days, got %v", records)
+	}
+}
+
+func TestForceAddKeepsUpdated(t *testing.T) {
+	opts := opts("forceaddtest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+	_, _ = app.AddToVault(opts, []string{"filea"})
+	before, _ := app.LsLong(opts)
+
+	// Updated has a resolution of one second
+	time.Sleep(1100 * time.Millisecond)
+	opts.Force = true
+	_, err := app.AddToVault(opts, []string{"filea"})
+	if err != nil {
+		t.Fatalf("failed to add filea again %s", err)
+	}
+	after, _ := app.LsLong(opts)
+	if !after[0].Updated.Equal(before[0].Updated) {
+		t.Errorf("adding unchanged contents changed updated from %s to %s", before[0].Updated, after[0].Updated)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "filea"), []byte(data2), 0644)
+	_, _ = app.AddToVault(opts, []string{"filea"})
+	after, _ = app.LsLong(opts)
+	if !after[0].Updated.After(before[0].Updated) {
+		t.Errorf("adding changed contents did not change updated from %s", before[0].Updated)
+	}
+}
@@ -19,13 +19,13 @@ func TestAddFile(t *testing.T) {
 
 	files := createFilesA(opts.Wd)
 
-	err := app.AddToVault(opts, files)
+	_, err := app.AddToVault(opts, files)
 	if err != nil {
 		t.Fatalf("failed to add files %s", err)
 	}
 
 	expected := []string{"filea", "foo/fileb"}
-	ls := app.Ls(opts)
+	ls, _ := app.Ls(opts)
 	if !reflect.DeepEqual(expected, ls) {
 		t.Fatalf("ls returned %s instead of %s", ls, expected)
 	}
@@ -37,18 +37,18 @@ func TestRemoveFile(t *testing.T) {
 
 	files := createFilesA(opts.Wd)
 
-	err := app.AddToVault(opts, files)
+	_, err := app.AddToVault(opts, files)
 	if err != nil {
 		t.Fatalf("failed to add files %s", err)
 	}
 
-	ls := app.Ls(opts)
+	ls, _ := app.Ls(opts)
 	if len(ls) != 2 {
 		t.Fatalf("expected ls to have 2 files, but it has %d", len(ls))
 	}
 
 	app.RemoveFromVault(opts, files[:1])
-	ls = app.Ls(opts)
+	ls, _ = app.Ls(opts)
 	if len(ls) != 1 {
 		t.Fatalf("expected ls to have 1 files, but it has %d", len(ls))
 	}
@@ -60,12 +60,12 @@ func TestRestoreFile(t *testing.T) {
 
 	filesA := createFilesA(opts.Wd)
 	filesB := createFilesB(opts.Wd)
-	err := app.AddToVault(opts, filesA)
+	_, err := app.AddToVault(opts, filesA)
 	if err != nil {
 		t.Fatalf("failed to add files %s", err)
 	}
 
-	app.CloseVault(opts)
+	app.CloseVault(opts, nil)
 
 	for _, f := range filesA {
 		_, err := os.Stat(f)
@@ -80,7 +80,7 @@ func TestRestoreFile(t *testing.T) {
 		}
 	}
 
-	app.OpenVault(opts)
+	app.OpenVault(opts, nil)
 
 	bytes, err := ioutil.ReadFile(filesA[0])
 	if err != nil {
@@ -100,25 +100,25 @@ func TestUpdateFile(t *testing.T) {
 
 	file := filepath.Join(opts.Wd, "filea")
 	_ = ioutil.WriteFile(file, []byte(data1), 0644)
-	err := app.AddToVault(opts, []string{"filea"})
+	_, err := app.AddToVault(opts, []string{"filea"})
 	if err != nil {
 		t.Fatalf("failed to add test file to vault %s", err)
 	}
 
 	_ = ioutil.WriteFile(file, []byte(data2), 0644)
 
-	err = app.AddToVault(opts, []string{file})
+	_, err = app.AddToVault(opts, []string{file})
 	if err == nil {
 		t.Fatal("should have failed to add changed test file to vault")
 	}
-	app.OpenVault(opts)
+	app.OpenVault(opts, nil)
 	bytes, _ := ioutil.ReadFile(file)
 	if string(bytes) != data2 {
 		t.Fatal("open should not have changed the file without force")
 	}
 
 	opts.Force = true
-	app.OpenVault(opts)
+	app.OpenVault(opts, nil)
 	bytes, _ = ioutil.ReadFile(file)
 	if string(bytes) != data1 {
 		t.Fatal("open should have changed the file with force")
@@ -127,18 +127,18 @@ func TestUpdateFile(t *testing.T) {
 	opts.Force = false
 	_ = ioutil.WriteFile(file, []byte(data2), 0644)
 
-	err = app.Commit(opts)
+	_, err = app.Commit(opts)
 	if err != nil {
 		t.Fatalf("failed to commit change to vault %s", err)
 	}
 
-	app.CloseVault(opts)
+	app.CloseVault(opts, nil)
 	_, err = os.Stat(file)
 	if !os.IsNotExist(err) {
 		t.Errorf("failed to delete %s", file)
 	}
 
-	app.OpenVault(opts)
+	app.OpenVault(opts, nil)
 
 	bytes, _ = ioutil.ReadFile(file)
 	if string(bytes) != data2 {
@@ -146,6 +146,61 @@ func TestUpdateFile(t *testing.T) {
 	}
 }
 
+func TestSelectiveOpenAndClose(t *testing.T) {
+	opts := opts("selectivetest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+
+	_, _ = app.AddToVault(opts, []string{"dir1", "dir2"})
+
+	exists := func(path string) bool {
+		_, err := os.Stat(filepath.Join(opts.Wd, path))
+		return err == nil
+	}
+
+	opts.Exclude = []string{"dir1/dir11/**"}
+	app.CloseVault(opts, []string{"dir1"})
+	if exists("dir1/filea1") || exists("dir1/dir12/fileb12") {
+		t.Error("expected files in dir1 to be deleted")
+	}
+	if !exists("dir1/dir11/filea11") {
+		t.Error("expected excluded files not to be deleted")
+	}
+	if !exists("dir2/filea2") {
+		t.Error("expected files which do not match not to be deleted")
+	}
+
+	opts.Exclude = nil
+	app.CloseVault(opts, nil)
+	app.OpenVault(opts, []string{"**/filea*"})
+	if !exists("dir1/filea1") || !exists("dir2/dir22/filea22") {
+		t.Error("expected files matching the glob to be restored")
+	}
+	if exists("dir1/fileb1") {
+		t.Error("expected files which do not match the glob not to be restored")
+	}
+
+	// the project root and its subdirectories can be given from anywhere in the project
+	_, err := app.OpenVault(opts, []string{"."})
+	if err != nil || !exists("dir1/fileb1") || !exists("dir2/dir21/fileb21") {
+		t.Errorf("expected open . to restore every file: %s", err)
+	}
+	_, err = app.CloseVault(opts, []string{"dir2/"})
+	if err != nil || exists("dir2/filea2") || !exists("dir1/filea1") {
+		t.Errorf("expected close dir2/ to only delete files in dir2: %s", err)
+	}
+	subOpts := opts
+	subOpts.Wd = filepath.Join(opts.Wd, "dir2")
+	_, err = app.OpenVault(subOpts, []string{"dir21"})
+	if err != nil || !exists("dir2/dir21/filea21") || exists("dir2/dir22/filea22") {
+		t.Errorf("expected open dir21 from dir2 to restore only files in dir2/dir21: %s", err)
+	}
+	_, err = app.CloseVault(subOpts, []string{".."})
+	if err != nil || exists("dir1/filea1") || exists("dir2/dir21/filea21") {
+		t.Errorf("expected close .. from dir2 to delete every file: %s", err)
+	}
+}
+
 func createFilesA(projectdir string) []string {
 	foodir := filepath.Join(projectdir, "foo")
 	filea := filepath.Join(projectdir, "filea")
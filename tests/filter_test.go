@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestFilterCleanAndSmudge(t *testing.T) {
+	opts := opts("filtertest")
+	setupVault(t, opts)
+
+	clean := func(data string) ([]byte, error) {
+		var out bytes.Buffer
+		err := app.FilterClean(opts, "filea", strings.NewReader(data), &out)
+		return out.Bytes(), err
+	}
+	smudge := func(data []byte) []byte {
+		var out bytes.Buffer
+		err := app.FilterSmudge(opts, "filea", bytes.NewReader(data), &out)
+		if err != nil {
+			t.Fatalf("smudge failed: %s", err)
+		}
+		return out.Bytes()
+	}
+
+	encrypted, err := clean(data1)
+	if err != nil {
+		t.Fatalf("clean failed: %s", err)
+	}
+	if bytes.Contains(encrypted, []byte(data1)) {
+		t.Fatal("expected clean to encrypt the data")
+	}
+	again, _ := clean(data1)
+	if !bytes.Equal(encrypted, again) {
+		t.Error("expected clean to be deterministic")
+	}
+	different, _ := clean(data2)
+	if bytes.Equal(encrypted, different) {
+		t.Error("expected different data to encrypt differently")
+	}
+	passthrough, _ := clean(string(encrypted))
+	if !bytes.Equal(encrypted, passthrough) {
+		t.Error("expected clean to pass through encrypted data")
+	}
+
+	if string(smudge(encrypted)) != data1 {
+		t.Error("expected smudge to decrypt the data")
+	}
+	if string(smudge([]byte(data2))) != data2 {
+		t.Error("expected smudge to pass through plaintext")
+	}
+
+	opts.Force = true
+	_, _ = app.UnsetKey(opts)
+	if !bytes.Equal(smudge(encrypted), encrypted) {
+		t.Error("expected smudge to pass through encrypted data without the key")
+	}
+	if _, err := clean(data1); err == nil {
+		t.Error("expected clean to fail without the key")
+	}
+}
+
+func TestFilterProfile(t *testing.T) {
+	opts := opts("filterprofiletest")
+	setupVault(t, opts)
+	_, _ = app.AddProfile(opts, "prod")
+	prod := opts
+	prod.Profile = "prod"
+
+	var encrypted bytes.Buffer
+	if err := app.FilterClean(prod, "filea", strings.NewReader(data1), &encrypted); err != nil {
+		t.Fatalf("clean failed: %s", err)
+	}
+	var out bytes.Buffer
+	_ = app.FilterSmudge(opts, "filea", bytes.NewReader(encrypted.Bytes()), &out)
+	if !bytes.Equal(out.Bytes(), encrypted.Bytes()) {
+		t.Error("expected the data cleaned with the profile not to be decrypted with the key of the vault")
+	}
+	out.Reset()
+	_ = app.FilterSmudge(prod, "filea", bytes.NewReader(encrypted.Bytes()), &out)
+	if out.String() != data1 {
+		t.Error("expected smudge to decrypt the data with the key of the profile")
+	}
+}
+
+func TestInstallFilter(t *testing.T) {
+	opts := opts("installfiltertest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+	createFilesC(opts.Wd)
+
+	if _, err := app.InstallFilter(opts); err == nil {
+		t.Error("expected install to fail without glob patterns")
+	}
+
+	_, _ = app.AddToVault(opts, []string{"dir1/file*"})
+	warnings, err := app.InstallFilter(opts)
+	if err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected a warning for each ignored secret, got %s", warnings)
+	}
+
+	attributes := gitRun(t, opts.Wd, "check-attr", "filter", "--", "dir1/filea1")
+	if !strings.Contains(attributes, "filter: lockgit") {
+		t.Errorf("expected dir1/filea1 to use the lockgit filter, got %s", attributes)
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/gitignore"
+)
+
+func TestManagedGitignoreBlock(t *testing.T) {
+	opts := opts("gitignoreblocktest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+	path := filepath.Join(opts.Wd, ".gitignore")
+	_ = ioutil.WriteFile(path, []byte("node_modules\nfilea\n"), 0644)
+
+	_, _ = app.AddToVault(opts, []string{"filea", "fileb", "dir1/**"})
+	expected := "node_modules\nfilea\n\n" + gitignore.BlockBegin + "\nfileb\ndir1/**\n" + gitignore.BlockEnd + "\n"
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != expected {
+		t.Errorf("expected .gitignore to be %q, got %q", expected, data)
+	}
+
+	// the plaintext of fileb is still present, so it stays ignored
+	app.RemoveFromVault(opts, []string{"fileb"})
+	managed, _ := gitignore.Managed(opts.Wd)
+	if !reflect.DeepEqual(managed, []string{"fileb", "dir1/**"}) {
+		t.Errorf("expected rm to keep fileb in the block, got %v", managed)
+	}
+
+	// lines outside of the block are not changed
+	_, _ = app.CloseVault(opts, nil)
+	app.RemoveFromVault(opts, []string{"filea", "dir1/**"})
+	expected = "node_modules\nfilea\n\n" + gitignore.BlockBegin + "\nfileb\n" + gitignore.BlockEnd + "\n"
+	data, _ = ioutil.ReadFile(path)
+	if string(data) != expected {
+		t.Errorf("expected .gitignore to be %q, got %q", expected, data)
+	}
+}
+
+func TestGitignoreCheck(t *testing.T) {
+	opts := opts("gitignorechecktest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+	createFilesC(opts.Wd)
+	_, _ = app.AddToVault(opts, []string{"dir1/**/filea*"})
+
+	paths, err := app.CheckGitignore(opts)
+	if err != nil || len(paths) != 0 {
+		t.Errorf("expected every secret to be ignored, got %v: %s", paths, err)
+	}
+
+	// a nested .gitignore file can include a file again
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "dir11", ".gitignore"), []byte("!filea11\n"), 0644)
+	// and files added with --no-update-gitignore are not ignored at all
+	noUpdate := opts
+	noUpdate.NoUpdateGitignore = true
+	_, _ = app.AddToVault(noUpdate, []string{"fileb"})
+
+	paths, err = app.CheckGitignore(opts)
+	expected := []string{"dir1/dir11/filea11", "fileb"}
+	if _, ok := err.(*app.NotIgnoredError); !ok || !reflect.DeepEqual(expected, paths) {
+		t.Errorf("expected %v not to be ignored, got %v: %s", expected, paths, err)
+	}
+}
+
+func TestGitignoreFiles(t *testing.T) {
+	dir := opts("gitignorefilestest").Wd
+	cleanDir(dir)
+	createFilesC(dir)
+
+	files := func(patterns ...string) []string {
+		t.Helper()
+		absPaths, err := gitignore.Files(dir, patterns)
+		if err != nil {
+			t.Fatalf("failed to find files for %v: %s", patterns, err)
+		}
+		relPaths := make([]string, len(absPaths))
+		for i, absPath := range absPaths {
+			relPaths[i], _ = filepath.Rel(dir, absPath)
+		}
+		return relPaths
+	}
+
+	if matched := files(); len(matched) != 0 {
+		t.Errorf("expected no patterns to match no files, got %v", matched)
+	}
+	// directories which cannot contain a match are skipped, but not ones a later pattern includes again
+	tests := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{"dir1/*"}, []string{"dir1/dir11/filea11", "dir1/dir11/fileb11", "dir1/dir12/filea12", "dir1/dir12/fileb12", "dir1/filea1", "dir1/fileb1"}},
+		{[]string{"dir2/dir21/filea*"}, []string{"dir2/dir21/filea21"}},
+		{[]string{"filea2*", "!dir2/"}, []string{}},
+		{[]string{"dir2/**", "!dir2/dir2*", "dir2/dir22/fileb*"}, []string{"dir2/dir22/fileb22", "dir2/filea2", "dir2/fileb2"}},
+	}
+	for _, test := range tests {
+		if matched := files(test.patterns...); !reflect.DeepEqual(matched, test.expected) {
+			t.Errorf("%v matched %v instead of %v", test.patterns, matched, test.expected)
+		}
+	}
+}
+
+func TestMoveUpdatesGitignore(t *testing.T) {
+	opts := opts("movetest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+	_, _ = app.AddToVault(opts, []string{"filea", "fileb", "dir1/**"})
+	owner := "ops"
+	_, _ = app.Annotate(opts, []string{"filea"}, app.Annotation{Owner: &owner})
+
+	changes, err := app.Move(opts, "filea", filepath.Join("renamed", "filea"))
+	if err != nil || len(changes) != 1 || changes[0].From != "filea" {
+		t.Fatalf("expected filea to be moved, got %v: %s", changes, err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.Wd, "renamed", "filea")); err != nil {
+		t.Errorf("expected the plaintext to be moved")
+	}
+	secrets, _ := app.LsLong(opts)
+	if secrets[len(secrets)-1].Path != "renamed/filea" || secrets[len(secrets)-1].Owner != owner {
+		t.Errorf("expected renamed/filea to keep its metadata, got %v", secrets)
+	}
+	managed, _ := gitignore.Managed(opts.Wd)
+	if !reflect.DeepEqual(managed, []string{"fileb", "dir1/**", "renamed/filea"}) {
+		t.Errorf("expected the .gitignore line to follow the secret, got %v", managed)
+	}
+
+	// secrets in a directory are moved into a directory which exists
+	_ = os.Mkdir(filepath.Join(opts.Wd, "dir3"), 0755)
+	changes, err = app.Move(opts, "dir1/dir11", "dir3")
+	if err != nil || len(changes) != 2 || changes[0].Path != "dir3/dir11/filea11" {
+		t.Errorf("expected the secrets in dir1/dir11 to be moved into dir3, got %v: %s", changes, err)
+	}
+	managed, _ = gitignore.Managed(opts.Wd)
+	if !reflect.DeepEqual(managed, []string{"fileb", "dir1/**", "renamed/filea", "dir3/dir11/filea11", "dir3/dir11/fileb11"}) {
+		t.Errorf("expected lines for the secrets outside of the pattern, got %v", managed)
+	}
+
+	if _, err := app.Move(opts, "fileb", "renamed/filea"); err == nil {
+		t.Errorf("expected moving onto a secret in the vault to fail")
+	}
+	if _, err := app.Move(opts, "filec", "filed"); err == nil {
+		t.Errorf("expected moving a file which is not in the vault to fail")
+	}
+}
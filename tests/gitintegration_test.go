@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestMergeManifests(t *testing.T) {
+	opts := opts("mergetest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+
+	tmp, _ := ioutil.TempDir("", "lockgit-merge")
+	defer os.RemoveAll(tmp)
+	saveManifest := func(name string) string {
+		path := filepath.Join(tmp, name)
+		data, _ := ioutil.ReadFile(filepath.Join(opts.Wd, ".lockgit", "manifest"))
+		_ = ioutil.WriteFile(path, data, 0644)
+		return path
+	}
+
+	files := createFilesA(opts.Wd)
+	_, _ = app.AddToVault(opts, files)
+	gitCommit(t, opts.Wd, "base")
+	base := saveManifest("base")
+	baseCommit := strings.TrimSpace(gitRun(t, opts.Wd, "rev-parse", "HEAD"))
+
+	// theirs changes fileb
+	gitRun(t, opts.Wd, "checkout", "-q", "-b", "theirs")
+	_ = ioutil.WriteFile(files[1], []byte(data1), 0600)
+	_, _ = app.Commit(opts)
+	gitCommit(t, opts.Wd, "change fileb")
+	theirs := saveManifest("theirs")
+
+	// conflicting changes filea
+	gitRun(t, opts.Wd, "checkout", "-q", "-b", "conflicting", baseCommit)
+	_ = ioutil.WriteFile(files[0], []byte("conflicting data"), 0644)
+	_ = ioutil.WriteFile(files[1], []byte(data2), 0600)
+	_, _ = app.Commit(opts)
+	gitCommit(t, opts.Wd, "change filea")
+	conflicting := saveManifest("conflicting")
+
+	// ours changes filea
+	gitRun(t, opts.Wd, "checkout", "-q", "-b", "ours", baseCommit)
+	_ = ioutil.WriteFile(files[0], []byte(data2), 0644)
+	_, _ = app.Commit(opts)
+	gitCommit(t, opts.Wd, "change filea")
+	ours := saveManifest("ours")
+
+	merged := filepath.Join(tmp, "merged")
+	copyFile(ours, merged)
+	conflicts, err := app.MergeManifests(opts, base, merged, theirs, "")
+	if err != nil {
+		t.Fatalf("merge failed: %s", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("expected changes to different secrets not to conflict")
+	}
+	mergedData, _ := ioutil.ReadFile(merged)
+	oursData, _ := ioutil.ReadFile(ours)
+	theirsData, _ := ioutil.ReadFile(theirs)
+	expected := strings.Split(string(oursData), "\n")[0] + "\n" + strings.Split(string(theirsData), "\n")[1] + "\n"
+	if string(mergedData) != expected {
+		t.Errorf("expected merged manifest to be\n%s\nbut got\n%s", expected, mergedData)
+	}
+
+	copyFile(ours, merged)
+	conflicts, err = app.MergeManifests(opts, base, merged, conflicting, "")
+	if err != nil {
+		t.Fatalf("merge failed: %s", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Path != "filea" {
+		t.Fatalf("expected filea to conflict")
+	}
+	if len(conflicts[0].Versions) != 3 {
+		t.Fatalf("expected 3 decrypted versions of filea, got %d", len(conflicts[0].Versions))
+	}
+	theirsVersion, _ := ioutil.ReadFile(conflicts[0].Versions[2])
+	if string(theirsVersion) != "conflicting data" {
+		t.Errorf("expected the decrypted version of the other branch to be written")
+	}
+
+	// a manifest from another branch cannot write the decrypted versions outside the merge directory
+	escaping := "../../../escaped"
+	oursLines := strings.Split(string(oursData), "\n")
+	conflictingData, _ := ioutil.ReadFile(conflicting)
+	conflictingLines := strings.Split(string(conflictingData), "\n")
+	_ = ioutil.WriteFile(merged, []byte(strings.Split(oursLines[0], "\t")[0]+"\t"+escaping+"\n"), 0644)
+	_ = ioutil.WriteFile(theirs, []byte(strings.Split(conflictingLines[0], "\t")[0]+"\t"+escaping+"\n"), 0644)
+	_ = ioutil.WriteFile(base, nil, 0644)
+	conflicts, err = app.MergeManifests(opts, base, merged, theirs, "")
+	if err != nil {
+		t.Fatalf("merge failed: %s", err)
+	}
+	if len(conflicts) != 1 || len(conflicts[0].Versions) != 0 {
+		t.Errorf("expected %s to conflict without decrypted versions, got %v", escaping, conflicts)
+	}
+	if _, err := os.Stat(filepath.Join(opts.Wd, "escaped.ours")); !os.IsNotExist(err) {
+		t.Errorf("decrypted version was written outside of the merge directory")
+	}
+}
+
+func TestMergeProfileManifests(t *testing.T) {
+	opts := opts("mergeprofiletest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+	_, _ = app.AddProfile(opts, "prod")
+	prod := opts
+	prod.Profile = "prod"
+
+	manifest := filepath.Join(opts.Wd, ".lockgit", "profiles", "prod", "manifest")
+	tmp, _ := ioutil.TempDir("", "lockgit-merge")
+	defer os.RemoveAll(tmp)
+	saveManifest := func(name string) string {
+		path := filepath.Join(tmp, name)
+		copyFile(manifest, path)
+		return path
+	}
+
+	secret := filepath.Join(opts.Wd, "prod.env")
+	_ = ioutil.WriteFile(secret, []byte(data1), 0644)
+	_, _ = app.AddToVault(prod, []string{"prod.env"})
+	gitCommit(t, opts.Wd, "base")
+	base := saveManifest("base")
+	baseCommit := strings.TrimSpace(gitRun(t, opts.Wd, "rev-parse", "HEAD"))
+
+	gitRun(t, opts.Wd, "checkout", "-q", "-b", "theirs")
+	_ = ioutil.WriteFile(secret, []byte("their data"), 0644)
+	_, _ = app.Commit(prod)
+	gitCommit(t, opts.Wd, "change prod.env")
+	theirs := saveManifest("theirs")
+
+	gitRun(t, opts.Wd, "checkout", "-q", "-b", "ours", baseCommit)
+	_ = ioutil.WriteFile(secret, []byte(data2), 0644)
+	_, _ = app.Commit(prod)
+	gitCommit(t, opts.Wd, "change prod.env")
+	merged := saveManifest("merged")
+
+	// the profile is found from the path of the manifest, so its key decrypts the versions
+	conflicts, err := app.MergeManifests(opts, base, merged, theirs, filepath.Join(".lockgit", "profiles", "prod", "manifest"))
+	if err != nil || len(conflicts) != 1 || len(conflicts[0].Versions) != 3 {
+		t.Fatalf("expected prod.env to conflict with 3 decrypted versions, got %v: %s", conflicts, err)
+	}
+	theirsVersion, _ := ioutil.ReadFile(conflicts[0].Versions[2])
+	if string(theirsVersion) != "their data" {
+		t.Errorf("expected the decrypted version of the other branch to be written")
+	}
+
+	// the diff driver decrypts the datafiles of a profile with its key
+	datafiles, _ := filepath.Glob(filepath.Join(opts.Wd, ".lockgit", "data", "*"))
+	for _, datafile := range datafiles {
+		if text, err := app.Textconv(opts, datafile); err != nil || !text.Decrypted || text.Path != "prod.env" {
+			t.Errorf("expected the datafiles of the prod profile to be decrypted, got %v: %s", text, err)
+		}
+	}
+}
+
+func TestInstallGitIntegration(t *testing.T) {
+	opts := opts("gitintegrationtest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+
+	err := app.InstallGitIntegration(opts)
+	if err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+	err = app.InstallGitIntegration(opts)
+	if err != nil {
+		t.Fatalf("second install failed: %s", err)
+	}
+
+	attributes := gitRun(t, opts.Wd, "check-attr", "merge", "--", ".lockgit/manifest")
+	if !strings.Contains(attributes, "merge: lockgit") {
+		t.Errorf("expected .lockgit/manifest to use the lockgit merge driver, got %s", attributes)
+	}
+	attributes = gitRun(t, opts.Wd, "check-attr", "merge", "--", ".lockgit/profiles/prod/manifest")
+	if !strings.Contains(attributes, "merge: lockgit") {
+		t.Errorf("expected the manifests of profiles to use the lockgit merge driver, got %s", attributes)
+	}
+	driver := gitRun(t, opts.Wd, "config", "merge.lockgit.driver")
+	if !strings.HasPrefix(driver, "lockgit merge-driver") {
+		t.Errorf("expected the merge driver to be configured, got %s", driver)
+	}
+}
+
+func copyFile(src, dst string) {
+	data, _ := ioutil.ReadFile(src)
+	_ = ioutil.WriteFile(dst, data, 0644)
+}
+
+func TestTextconv(t *testing.T) {
+	opts := opts("textconvtest")
+	setupVault(t, opts)
+
+	files := createFilesA(opts.Wd)
+	_, _ = app.AddToVault(opts, files)
+
+	datafiles, _ := filepath.Glob(filepath.Join(opts.Wd, ".lockgit", "data", "*"))
+	if len(datafiles) != 2 {
+		t.Fatalf("expected 2 datafiles")
+	}
+	for _, datafile := range datafiles {
+		text, err := app.Textconv(opts, datafile)
+		if err != nil {
+			t.Fatalf("textconv failed: %s", err)
+		}
+		if !text.Decrypted {
+			t.Fatalf("expected datafile to be decrypted")
+		}
+		if text.Path == "foo/fileb" && (string(text.Data) != data2 || text.Perm != 0600) {
+			t.Errorf("expected foo/fileb to be decrypted with its permissions")
+		}
+	}
+
+	// a datafile which cannot be decrypted must not make git diff fail
+	opts.Force = true
+	_, _ = app.SetKey(opts, testKey)
+	text, err := app.Textconv(opts, datafiles[0])
+	if err != nil || text.Decrypted || text.Digest == "" {
+		t.Errorf("expected a digest without decryption when the key is wrong, got %v: %s", text, err)
+	}
+
+	_, _ = app.UnsetKey(opts)
+	text, err = app.Textconv(opts, datafiles[0])
+	if err != nil || text.Decrypted || text.Digest == "" {
+		t.Errorf("expected a digest without decryption when there is no key")
+	}
+}
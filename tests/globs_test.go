@@ -1,12 +1,15 @@
 package tests
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/content"
 )
 
 func TestAddGlob(t *testing.T) {
@@ -14,39 +17,39 @@ func TestAddGlob(t *testing.T) {
 	setupVault(t, opts)
 	createFilesC(opts.Wd)
 
-	addedGlobs := app.LsGlobs(opts)
+	addedGlobs, _ := app.LsGlobs(opts)
 	if len(addedGlobs) != 0 {
 		t.Errorf("expected 0 globs in the vault")
 	}
 
 	app.AddToVault(opts, []string{"**/fileb*"})
-	addedFiles := app.Ls(opts)
+	addedFiles, _ := app.Ls(opts)
 	if len(addedFiles) != 7 {
 		t.Errorf("expected 7 files in the vault")
 	}
-	addedGlobs = app.LsGlobs(opts)
+	addedGlobs, _ = app.LsGlobs(opts)
 	if len(addedGlobs) != 1 {
 		t.Errorf("expected 1 globs in the vault")
 	}
 
 	// rerunning the same command should do nothing
 	app.AddToVault(opts, []string{"**/fileb*"})
-	addedFiles = app.Ls(opts)
+	addedFiles, _ = app.Ls(opts)
 	if len(addedFiles) != 7 {
 		t.Errorf("expected 7 files in the vault")
 	}
-	addedGlobs = app.LsGlobs(opts)
+	addedGlobs, _ = app.LsGlobs(opts)
 	if len(addedGlobs) != 1 {
 		t.Errorf("expected 1 globs in the vault")
 	}
 
 	// But a different command should add more files
 	app.AddToVault(opts, []string{"**/filea*"})
-	addedFiles = app.Ls(opts)
+	addedFiles, _ = app.Ls(opts)
 	if len(addedFiles) != 14 {
 		t.Errorf("expected 14 files in the vault")
 	}
-	addedGlobs = app.LsGlobs(opts)
+	addedGlobs, _ = app.LsGlobs(opts)
 	if len(addedGlobs) != 2 {
 		t.Errorf("expected 2 globs in the vault")
 	}
@@ -57,17 +60,17 @@ func TestRemoveGlob(t *testing.T) {
 	setupVault(t, opts)
 	createFilesC(opts.Wd)
 	app.AddToVault(opts, []string{"**/filea*"})
-	filecount := app.Ls(opts)
+	filecount, _ := app.Ls(opts)
 	if len(filecount) != 7 {
 		t.Errorf("expected 7 files in the vault")
 	}
 
 	app.RemoveFromVault(opts, []string{"**/filea*"})
-	filecount = app.Ls(opts)
+	filecount, _ = app.Ls(opts)
 	if len(filecount) != 0 {
 		t.Errorf("expected 0 files in the vault")
 	}
-	globcount := app.LsGlobs(opts)
+	globcount, _ := app.LsGlobs(opts)
 	if len(globcount) != 0 {
 		t.Errorf("expected 0 globs in the vault")
 	}
@@ -78,16 +81,16 @@ func TestDoNotAddGlobWithoutMatches(t *testing.T) {
 	setupVault(t, opts)
 	createFilesC(opts.Wd)
 
-	err := app.AddToVault(opts, []string{"**/filec*", "**/filea*"})
+	_, err := app.AddToVault(opts, []string{"**/filec*", "**/filea*"})
 	if err == nil {
 		t.Errorf("expected add to partially fail")
 	}
 
-	globs := app.LsGlobs(opts)
+	globs, _ := app.LsGlobs(opts)
 	if len(globs) != 1 || globs[0] != "**/filea*" {
 		t.Errorf("expected only one glob to be saved")
 	}
-	files := app.Ls(opts)
+	files, _ := app.Ls(opts)
 	if len(files) != 7 {
 		t.Errorf("expected to add 7 files")
 	}
@@ -98,9 +101,9 @@ func TestCommitNewViaGlob(t *testing.T) {
 	setupVault(t, opts)
 	createFilesC(opts.Wd)
 
-	_ = app.AddToVault(opts, []string{"dir1/dir12/**"})
+	_, _ = app.AddToVault(opts, []string{"dir1/dir12/**"})
 
-	files := app.Ls(opts)
+	files, _ := app.Ls(opts)
 	if len(files) != 2 {
 		t.Errorf("expected two files")
 	}
@@ -111,17 +114,129 @@ func TestCommitNewViaGlob(t *testing.T) {
 	_ = ioutil.WriteFile(f1, []byte(data2), 0644)
 	_ = ioutil.WriteFile(f2, []byte(data2), 0644)
 
-	err := app.Commit(opts)
+	_, err := app.Commit(opts)
 	if err != nil {
 		t.Errorf("commit to succeed")
 	}
 
-	files = app.Ls(opts)
+	files, _ = app.Ls(opts)
 	if len(files) != 3 {
 		t.Errorf("expected three files")
 	}
 }
 
+func TestNegatedPatterns(t *testing.T) {
+	opts := opts("negatedpatterntest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+
+	addOpts := opts
+	addOpts.Exclude = []string{"dir1/**/filea*", "dir1/dir12"}
+	_, err := app.AddToVault(addOpts, []string{"dir1"})
+	if err != nil {
+		t.Fatalf("failed to add files %s", err)
+	}
+
+	expected := []string{"dir1/dir11/fileb11", "dir1/fileb1"}
+	ls, _ := app.Ls(opts)
+	if !reflect.DeepEqual(expected, ls) {
+		t.Errorf("ls returned %s instead of %s", ls, expected)
+	}
+	expected = []string{"dir1/**", "!dir1/**/filea*", "!dir1/dir12/**"}
+	globs, _ := app.LsGlobs(opts)
+	if !reflect.DeepEqual(expected, globs) {
+		t.Errorf("globs returned %s instead of %s", globs, expected)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea9"), []byte(data1), 0644)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filec9"), []byte(data1), 0644)
+	records, _ := app.Status(opts)
+	if len(records) != 3 || records[2].Path != "dir1/filec9" || records[2].State != app.StateNew {
+		t.Errorf("expected only filec9 to be a new file, got %v", records)
+	}
+
+	changes, err := app.Commit(opts)
+	if err != nil || len(changes) != 1 || changes[0].Path != "dir1/filec9" {
+		t.Errorf("expected commit to only add filec9, got %v: %s", changes, err)
+	}
+
+	// a later pattern includes excluded files again
+	_, _ = app.AddToVault(opts, []string{"dir1/dir12/*"})
+	records, _ = app.Status(opts)
+	if len(records) != 5 || records[1].Path != "dir1/dir12/filea12" || records[1].Pattern != "dir1/**" {
+		t.Errorf("expected filea12 to be included again, got %v", records)
+	}
+}
+
+func TestGitignoreSyntax(t *testing.T) {
+	opts := opts("gitignoresyntaxtest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+
+	// a pattern without a slash matches at any depth below the working directory
+	subOpts := opts
+	subOpts.Wd = filepath.Join(opts.Wd, "dir1")
+	_, err := app.AddToVault(subOpts, []string{"filea*"})
+	if err != nil {
+		t.Fatalf("failed to add files %s", err)
+	}
+	// a pattern ending with a slash only matches directories, and everything inside of them
+	_, err = app.AddToVault(opts, []string{"dir21/"})
+	if err != nil {
+		t.Fatalf("failed to add files %s", err)
+	}
+
+	expected := []string{"dir1/**/filea*", "dir21/"}
+	globs, _ := app.LsGlobs(opts)
+	if !reflect.DeepEqual(expected, globs) {
+		t.Errorf("globs returned %s instead of %s", globs, expected)
+	}
+	expected = []string{"dir1/dir11/filea11", "dir1/dir12/filea12", "dir1/filea1", "dir2/dir21/filea21", "dir2/dir21/fileb21"}
+	ls, _ := app.Ls(opts)
+	if !reflect.DeepEqual(expected, ls) {
+		t.Errorf("ls returned %s instead of %s", ls, expected)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "dir11", "filea9"), []byte(data1), 0644)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir2", "filea9"), []byte(data1), 0644)
+	records, _ := app.Status(opts)
+	if len(records) != 6 || records[1].Path != "dir1/dir11/filea9" || records[1].Pattern != "dir1/**/filea*" {
+		t.Errorf("expected only dir1/dir11/filea9 to be new, got %v", records)
+	}
+
+	changes, _ := app.RemoveFromVault(subOpts, []string{"filea*"})
+	if len(changes) != 4 || changes[0].Path != "dir1/**/filea*" {
+		t.Errorf("expected rm to remove the pattern and 3 files, got %v", changes)
+	}
+}
+
+func TestLegacyPatternSyntax(t *testing.T) {
+	opts := opts("legacypatterntest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+
+	// vaults created before the .gitignore syntax was added do not have the flag set
+	configPath := filepath.Join(opts.Wd, ".lockgit", "lgconfig")
+	data, _ := ioutil.ReadFile(configPath)
+	config := content.LgConfig{}
+	_ = json.Unmarshal(data, &config)
+	config.GitignoreSyntax = false
+	config.Write(configPath)
+
+	_, _ = app.AddToVault(opts, []string{"dir1/*"})
+	expected := []string{"dir1/filea1", "dir1/fileb1"}
+	ls, _ := app.Ls(opts)
+	if !reflect.DeepEqual(expected, ls) {
+		t.Errorf("ls returned %s instead of %s", ls, expected)
+	}
+
+	_, _ = app.AddToVault(opts, []string{"filea2*"})
+	records, _ := app.Status(opts)
+	if len(records) != 2 {
+		t.Errorf("expected a pattern without a slash to only match in the project root, got %v", records)
+	}
+}
+
 func createFilesC(projectdir string) {
 	d1 := filepath.Join(projectdir, "dir1")
 	d2 := filepath.Join(projectdir, "dir2")
@@ -2,40 +2,58 @@ package tests
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
 	"github.com/jswidler/lockgit/pkg/app"
-	"github.com/jswidler/lockgit/pkg/cmd"
-	"github.com/spf13/viper"
+	"github.com/jswidler/lockgit/pkg/keystore"
 )
 
 func setupVault(t *testing.T, opts app.Options) {
 	cleanDir(opts.Wd)
 
-	reloadConfig(opts)
-
 	// Initialize the vault
-	err := app.InitVault(opts)
+	_, err := app.InitVault(opts)
 	if err != nil {
 		t.Fatal("InitVault returned an error", err)
 	}
-
-	// Reload the config to get the key
-	reloadConfig(opts)
-}
-
-func reloadConfig(opts app.Options) {
-	viper.Reset()
-	cmd.InitConfig(filepath.Join(opts.Wd, "config.yml"))
 }
 
+// Options for a vault in the test directory.  The keys are saved in config.yml in the directory.
 func opts(dir string) app.Options {
 	wd, _ := os.Getwd()
-	return app.Options{Wd: filepath.Join(wd, "..", "build", "test", "vaults", dir)}
+	vaultDir := filepath.Join(wd, "..", "build", "test", "vaults", dir)
+	return app.Options{Wd: vaultDir, KeyStore: keystore.NewFile(filepath.Join(vaultDir, "config.yml"))}
 }
 
 func cleanDir(path string) {
 	_ = os.RemoveAll(path)
 	_ = os.MkdirAll(path, 0755)
 }
+
+func setupGitRepo(t *testing.T, dir string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitRun(t, dir, "init", "-q")
+}
+
+func gitCommit(t *testing.T, dir string, message string) {
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "commit", "-q", "--allow-empty", "-m", message)
+}
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Lockgit Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Lockgit Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s\n%s", args[0], err, out)
+	}
+	return string(out)
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestHistory(t *testing.T) {
+	opts := opts("historytest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+
+	file := filepath.Join(opts.Wd, "filea")
+	_ = ioutil.WriteFile(file, []byte(data1), 0644)
+	_, _ = app.AddToVault(opts, []string{file})
+	gitCommit(t, opts.Wd, "add filea")
+
+	_ = ioutil.WriteFile(file, []byte(data2), 0644)
+	_, _ = app.Commit(opts)
+	gitCommit(t, opts.Wd, "change filea")
+
+	app.RemoveFromVault(opts, []string{file})
+	gitCommit(t, opts.Wd, "remove filea")
+
+	history, err := app.History(opts, nil, true)
+	if err != nil {
+		t.Fatalf("history failed: %s", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 entries in the history but there are %d", len(history))
+	}
+
+	expected := []app.ChangeType{app.Removed, app.Changed, app.Added}
+	for i, entry := range history {
+		if len(entry.Changes) != 1 {
+			t.Fatalf("expected 1 change in entry %d", i)
+		}
+		change := entry.Changes[0]
+		if change.Path != "filea" || change.Type != expected[i] {
+			t.Errorf("expected entry %d to be %s filea, got %s %s", i, expected[i], change.Type, change.Path)
+		}
+		if entry.Author != "Lockgit Test" {
+			t.Errorf("expected author to be recorded")
+		}
+	}
+
+	if !strings.Contains(history[1].Changes[0].Diff, "-"+data1) || !strings.Contains(history[1].Changes[0].Diff, "+"+data2) {
+		t.Errorf("expected diff to show the change in content, got:\n%s", history[1].Changes[0].Diff)
+	}
+
+	history, err = app.History(opts, []string{"other"}, false)
+	if err != nil {
+		t.Fatalf("history failed: %s", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("expected no history for a path that was never in the vault")
+	}
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestPreCommitCheck(t *testing.T) {
+	opts := opts("precommittest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+	createFilesC(opts.Wd)
+
+	_, _ = app.AddToVault(opts, []string{"dir1/file*"})
+	gitCommit(t, opts.Wd, "add secrets")
+
+	problems, err := app.PreCommitCheck(opts)
+	if err != nil {
+		t.Fatalf("pre-commit check failed: %s", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("expected no problems, got %s", problems)
+	}
+
+	gitRun(t, opts.Wd, "add", "-f", filepath.Join("dir1", "filea1"))
+	problems, _ = app.PreCommitCheck(opts)
+	if len(problems) != 1 {
+		t.Errorf("expected the staged secret to be reported, got %s", problems)
+	}
+	gitRun(t, opts.Wd, "reset", "-q")
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea15"), []byte(data2), 0644)
+	problems, _ = app.PreCommitCheck(opts)
+	if len(problems) != 2 {
+		t.Errorf("expected the changed and new secrets to be reported, got %s", problems)
+	}
+}
+
+func TestInstallHook(t *testing.T) {
+	opts := opts("installhooktest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+
+	hookPath := filepath.Join(opts.Wd, ".git", "hooks", "pre-commit")
+	_ = ioutil.WriteFile(hookPath, []byte("#!/bin/sh\nexit 0\n"), 0755)
+
+	if err := app.InstallHook(opts); err == nil {
+		t.Error("expected install to fail when a hook exists")
+	}
+
+	opts.Force = true
+	if err := app.InstallHook(opts); err != nil {
+		t.Fatalf("expected install to replace the hook with force: %s", err)
+	}
+	info, err := os.Stat(hookPath)
+	if err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected the hook to be executable")
+	}
+
+	opts.Force = false
+	if err := app.InstallHook(opts); err != nil {
+		t.Errorf("expected install to succeed when the hook is already installed: %s", err)
+	}
+}
+
+func TestPreCommitHookInSubdirectory(t *testing.T) {
+	repo := opts("precommitsubdirtest").Wd
+	cleanDir(repo)
+	setupGitRepo(t, repo)
+	opts := opts(filepath.Join("precommitsubdirtest", "vault"))
+	_ = os.MkdirAll(opts.Wd, 0755)
+	if _, err := app.InitVault(opts); err != nil {
+		t.Fatalf("failed to init vault %s", err)
+	}
+	if _, err := app.AddProfile(opts, "prod"); err != nil {
+		t.Fatalf("failed to add profile %s", err)
+	}
+	prod := opts
+	prod.Profile = "prod"
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "prod.env"), []byte(data1), 0644)
+	_, _ = app.AddToVault(prod, []string{"prod.env"})
+	gitCommit(t, repo, "add vault")
+
+	if err := app.InstallHook(opts); err != nil {
+		t.Fatalf("failed to install hook %s", err)
+	}
+	// git runs the hook from the top of the work tree, so it must change to the vault's directory
+	bin := filepath.Join(repo, "bin")
+	_ = os.Mkdir(bin, 0755)
+	_ = ioutil.WriteFile(filepath.Join(bin, "lockgit"), []byte("#!/bin/sh\npwd > \"$HOOK_WD\"\n"), 0755)
+	hook := exec.Command("sh", filepath.Join(repo, ".git", "hooks", "pre-commit"))
+	hook.Dir = repo
+	hook.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"), "HOOK_WD="+filepath.Join(repo, "hookwd"))
+	if out, err := hook.CombinedOutput(); err != nil {
+		t.Fatalf("failed to run hook %s: %s", err, out)
+	}
+	hookWd, _ := ioutil.ReadFile(filepath.Join(repo, "hookwd"))
+	if expected, _ := filepath.EvalSymlinks(opts.Wd); strings.TrimSpace(string(hookWd)) != expected {
+		t.Errorf("expected the hook to run in %s, ran in %s", expected, hookWd)
+	}
+
+	// secrets in every profile are checked, not only the default one
+	gitRun(t, repo, "add", "-f", filepath.Join("vault", "prod.env"))
+	problems, err := app.PreCommitCheck(opts)
+	if err != nil || len(problems) != 1 {
+		t.Errorf("expected the staged secret of the prod profile to be reported, got %s: %s", problems, err)
+	}
+}
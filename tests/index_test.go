@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/keystore"
+)
+
+func TestLocalIndex(t *testing.T) {
+	opts := opts("indextest")
+	setupVault(t, opts)
+
+	filea := filepath.Join(opts.Wd, "filea")
+	fileb := filepath.Join(opts.Wd, "fileb")
+	_ = ioutil.WriteFile(filea, []byte(data1), 0644)
+	_ = ioutil.WriteFile(fileb, []byte(data2), 0600)
+	_, err := app.AddToVault(opts, []string{"filea", "fileb"})
+	if err != nil {
+		t.Fatalf("failed to add files to vault %s", err)
+	}
+	owner := "payments-team"
+	_, _ = app.Annotate(opts, []string{"fileb"}, app.Annotation{Owner: &owner, AddTags: []string{"stripe"}})
+	// files modified in the same second the index is written are not trusted
+	past := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(filea, past, past)
+	_ = os.Chtimes(fileb, past, past)
+
+	_, _ = app.Status(opts)
+	if _, err := os.Stat(filepath.Join(opts.Wd, ".lockgit", "local", "index")); err != nil {
+		t.Fatalf("status did not write the local index %s", err)
+	}
+	ignore, _ := ioutil.ReadFile(filepath.Join(opts.Wd, ".lockgit", "local", ".gitignore"))
+	if string(ignore) != "*\n" {
+		t.Errorf("the local index is not ignored by git")
+	}
+	index, _ := ioutil.ReadFile(filepath.Join(opts.Wd, ".lockgit", "local", "index"))
+	if strings.Contains(string(index), owner) || strings.Contains(string(index), "stripe") {
+		t.Errorf("the local index contains the metadata of a secret in plaintext")
+	}
+	records, _ := app.Status(opts)
+	if records[1].Owner != owner || len(records[1].Tags) != 1 {
+		t.Errorf("expected the metadata of fileb from the index, got %v", records[1])
+	}
+
+	// without the key, the secrets cannot be decrypted, so the state must come from the index
+	noKey := opts
+	noKey.KeyStore = keystore.NewMemory()
+	expectStates(t, noKey, app.StateUnchanged, app.StateUnchanged)
+	records, _ = app.Status(noKey)
+	if records[1].Owner != "" || records[1].Perm != "0600" {
+		t.Errorf("expected only the permissions of fileb without the key, got %v", records[1])
+	}
+
+	_ = ioutil.WriteFile(filea, []byte(data2), 0644)
+	expectStates(t, noKey, app.StateUnknown, app.StateUnchanged)
+	expectStates(t, opts, app.StateUpdated, app.StateUnchanged)
+
+	_, _ = app.CloseVault(opts, []string{"fileb"})
+	records, _ = app.Status(noKey)
+	if records[1].State != app.StateUnavailable || records[1].Perm != "0600" {
+		t.Errorf("closed secret should be unavailable with its permissions, got %v", records[1])
+	}
+
+	_, err = app.Commit(opts)
+	if err != nil {
+		t.Fatalf("failed to commit change to vault %s", err)
+	}
+	_ = os.Chtimes(filea, past, past)
+	expectStates(t, opts, app.StateUnchanged, app.StateUnavailable)
+	expectStates(t, noKey, app.StateUnchanged, app.StateUnavailable)
+}
+
+func TestLocalIndexProfiles(t *testing.T) {
+	opts := opts("indexprofiletest")
+	setupVault(t, opts)
+	_, _ = app.AddProfile(opts, "prod")
+	prod := opts
+	prod.Profile = "prod"
+
+	filea := filepath.Join(opts.Wd, "filea")
+	prodfile := filepath.Join(opts.Wd, "prod.env")
+	_ = ioutil.WriteFile(filea, []byte(data1), 0644)
+	_ = ioutil.WriteFile(prodfile, []byte(data2), 0644)
+	_, _ = app.AddToVault(opts, []string{"filea"})
+	_, _ = app.AddToVault(prod, []string{"prod.env"})
+	past := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(filea, past, past)
+	_ = os.Chtimes(prodfile, past, past)
+
+	_, _ = app.Status(opts)
+	_, _ = app.Status(prod)
+	if _, err := os.Stat(filepath.Join(opts.Wd, ".lockgit", "local", "index.prod")); err != nil {
+		t.Fatalf("expected the prod profile to have its own index: %s", err)
+	}
+
+	// the index of each profile is kept when the other is used, so the state is known without the keys
+	noKey := opts
+	noKey.KeyStore = keystore.NewMemory()
+	expectStates(t, noKey, app.StateUnchanged)
+	noKey.Profile = "prod"
+	expectStates(t, noKey, app.StateUnchanged)
+}
+
+func expectStates(t *testing.T, opts app.Options, states ...app.FileState) {
+	t.Helper()
+	records, err := app.Status(opts)
+	if err != nil {
+		t.Fatalf("status failed %s", err)
+	}
+	if len(records) != len(states) {
+		t.Fatalf("expected %d status records, got %v", len(states), records)
+	}
+	for i, state := range states {
+		if records[i].State != state {
+			t.Errorf("%s should be %s but is %s", records[i].Path, state, records[i].State)
+		}
+	}
+}
@@ -23,7 +23,7 @@ func TestInitVaultFailsIfVaultExists(t *testing.T) {
 	setupVault(t, opts)
 
 	// Re-initialize the vault
-	err := app.InitVault(opts)
+	_, err := app.InitVault(opts)
 	if err == nil {
 		t.Error("expected vault init to fail, but it didn't")
 	}
@@ -14,23 +14,25 @@ func TestSetKey(t *testing.T) {
 	setupVault(t, opts)
 
 	// Delete the original key
-	reloadConfig(opts)
 	opts.Force = true
-	err := app.UnsetKey(opts)
+	_, err := app.UnsetKey(opts)
 	if err != nil {
 		t.Errorf("unset key failed %s", err)
 	}
 
 	// Now try and set the new key
 	opts.Force = false
-	reloadConfig(opts)
-	err = app.SetKey(opts, testKey)
+	record, err := app.SetKey(opts, testKey)
 	if err != nil {
 		t.Errorf("set key failed %s", err)
 	}
+	ctx, _ := content.FromPath(opts.Wd, opts.KeyStore)
+	expected := app.KeyRecord{Id: ctx.Config.Id, Path: opts.Wd, KeyStore: opts.KeyStore.String()}
+	if record != expected {
+		t.Errorf("set key returned %v instead of %v", record, expected)
+	}
 
-	reloadConfig(opts)
-	key := app.GetKey(opts)
+	key, _ := app.GetKey(opts)
 	if key != testKey {
 		t.Error("key was not successfully recalled")
 	}
@@ -40,7 +42,7 @@ func TestSetKeyFailsIfKeyIsSet(t *testing.T) {
 	opts := opts("setkeyfail")
 	setupVault(t, opts)
 
-	err := app.SetKey(opts, testKey)
+	_, err := app.SetKey(opts, testKey)
 	if err == nil {
 		t.Error("expected set key to fail and not overwrite key without force")
 	}
@@ -51,16 +53,15 @@ func TestSetKeyOverwritesWithForce(t *testing.T) {
 	setupVault(t, opts)
 
 	// Check get key does not panic
-	_ = app.GetKey(opts)
+	_, _ = app.GetKey(opts)
 
 	opts.Force = true
-	err := app.SetKey(opts, testKey)
+	_, err := app.SetKey(opts, testKey)
 	if err != nil {
 		t.Errorf("set key failed :%s", err)
 	}
 
-	reloadConfig(opts)
-	key := app.GetKey(opts)
+	key, _ := app.GetKey(opts)
 	if key != testKey {
 		t.Error("key was not successfully recalled")
 	}
@@ -70,24 +71,24 @@ func TestUnsetKey(t *testing.T) {
 	opts := opts("unsetkey")
 	setupVault(t, opts)
 
-	err := app.UnsetKey(opts)
+	_, err := app.UnsetKey(opts)
 	if err == nil {
 		t.Error("expected unset-key to fail without force")
 	}
 
 	opts.Force = true
-	err = app.UnsetKey(opts)
+	_, err = app.UnsetKey(opts)
 	if err != nil {
 		t.Errorf("expected unset-key to work with force: %s", err)
 	}
 
-	err = app.UnsetKey(opts)
+	_, err = app.UnsetKey(opts)
 	if err == nil {
 		t.Error("expected unset-key to fail when the key is unset")
 	}
 
 	opts.Force = false
-	_, err = content.FromPath(opts.Wd)
+	_, err = content.FromPath(opts.Wd, opts.KeyStore)
 	if !content.IsKeyLoadError(err) {
 		t.Error("expected a KeyLoadError after unsetting the key")
 	}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/content"
+	"github.com/jswidler/lockgit/pkg/keystore"
+)
+
+func TestKeyStores(t *testing.T) {
+	dir := opts("keystores").Wd
+	cleanDir(dir)
+
+	stores := map[string]keystore.KeyStore{
+		"file":   keystore.NewFile(filepath.Join(dir, "config.yml")),
+		"dir":    keystore.NewDir(filepath.Join(dir, "keys")),
+		"memory": keystore.NewMemory(),
+	}
+	for name, store := range stores {
+		if entry, err := store.Get("vault1", ""); err != nil || entry.Key != "" {
+			t.Errorf("%s: expected no key before it is set, got %v %v", name, entry, err)
+		}
+		_ = store.Set(keystore.Entry{Id: "vault1", Key: testKey, Path: "/projects/one"})
+		_ = store.Set(keystore.Entry{Id: "vault1", Profile: "prod", Key: "PRODKEY"})
+		_ = store.Set(keystore.Entry{Id: "vault2", Path: "/projects/two"})
+
+		entry, err := store.Get("vault1", "prod")
+		if err != nil || entry.Key != "PRODKEY" || entry.Path != "/projects/one" {
+			t.Errorf("%s: unexpected profile entry %v %v", name, entry, err)
+		}
+
+		expected := []keystore.Entry{
+			{Id: "vault1", Key: testKey, Path: "/projects/one"},
+			{Id: "vault1", Profile: "prod", Key: "PRODKEY"},
+			{Id: "vault2", Path: "/projects/two"},
+		}
+		if entries, err := store.List(); err != nil || !reflect.DeepEqual(entries, expected) {
+			t.Errorf("%s: list returned %v instead of %v", name, entries, expected)
+		}
+
+		_ = store.Delete("vault1", "prod")
+		if entry, _ := store.Get("vault1", "prod"); entry.Key != "" {
+			t.Errorf("%s: expected the profile key to be deleted, got %v", name, entry)
+		}
+		_ = store.Delete("vault1", "")
+		if entries, _ := store.List(); !reflect.DeepEqual(entries, expected[2:]) {
+			t.Errorf("%s: expected only vault2 after deleting vault1, got %v", name, entries)
+		}
+	}
+}
+
+func TestDirKeyStoreRejectsInvalidIds(t *testing.T) {
+	dir := opts("keystoreids").Wd
+	cleanDir(dir)
+
+	// vault ids come from the lgconfig in the repo and must not be able to escape the key directory
+	store := keystore.NewDir(filepath.Join(dir, "keys"))
+	for _, id := range []string{"../escaped", "sub/vault", ".."} {
+		if err := store.Set(keystore.Entry{Id: id, Key: testKey}); err == nil {
+			t.Errorf("expected set to fail for vault id %s", id)
+		}
+		if _, err := store.Get(id, ""); err == nil {
+			t.Errorf("expected get to fail for vault id %s", id)
+		}
+		if err := store.Delete(id, ""); err == nil {
+			t.Errorf("expected delete to fail for vault id %s", id)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped.yml")); !os.IsNotExist(err) {
+		t.Error("a key file was written outside of the key directory")
+	}
+}
+
+func TestEnvKeyStore(t *testing.T) {
+	opts := opts("envkeystore")
+	setupVault(t, opts)
+	key, _ := app.GetKey(opts)
+	ctx, _ := content.FromPath(opts.Wd, opts.KeyStore)
+
+	variable := "LOCKGIT_KEY_" + envId(ctx.Config.Id)
+	_ = os.Setenv(variable, key)
+	defer os.Unsetenv(variable)
+
+	opts.KeyStore = keystore.NewEnv()
+	createFilesA(opts.Wd)
+	if _, err := app.AddToVault(opts, []string{"filea"}); err != nil {
+		t.Errorf("failed to add a file with the key from the environment %s", err)
+	}
+	if _, err := app.SetKey(opts, testKey); err == nil {
+		t.Error("expected setting a key in the environment to fail")
+	}
+
+	_ = os.Unsetenv(variable)
+	if _, err := content.FromPath(opts.Wd, opts.KeyStore); !content.IsKeyLoadError(err) {
+		t.Errorf("expected a KeyLoadError without the variable, got %v", err)
+	}
+	_ = os.Setenv(keystore.EnvKey, key)
+	defer os.Unsetenv(keystore.EnvKey)
+	if _, err := content.FromPath(opts.Wd, opts.KeyStore); err != nil {
+		t.Errorf("expected the key to be read from %s, got %s", keystore.EnvKey, err)
+	}
+}
+
+func TestKeyStoreFromConfig(t *testing.T) {
+	dir := opts("keystoreconfig").Wd
+	cleanDir(dir)
+	config := filepath.Join(dir, "config.yml")
+
+	store, err := keystore.FromConfig(config)
+	if _, ok := store.(*keystore.File); !ok || err != nil {
+		t.Errorf("expected the config file to be the default key store, got %v %v", store, err)
+	}
+
+	_ = ioutil.WriteFile(config, []byte("keystore: dir\nkeystore-dir: "+filepath.Join(dir, "keys")+"\n"), 0644)
+	store, err = keystore.FromConfig(config)
+	if _, ok := store.(*keystore.Dir); !ok || err != nil || store.String() != filepath.Join(dir, "keys") {
+		t.Errorf("expected a dir key store, got %v %v", store, err)
+	}
+
+	_ = ioutil.WriteFile(config, []byte("keystore: safe\n"), 0644)
+	if _, err = keystore.FromConfig(config); err == nil {
+		t.Error("expected an unknown key store to be an error")
+	}
+}
+
+// The id of a vault as it appears in the name of an environment variable
+func envId(id string) string {
+	return strings.ToUpper(strings.ReplaceAll(id, "-", "_"))
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/lockgit"
+)
+
+func TestLibraryVault(t *testing.T) {
+	opts := opts("libraryvault")
+	setupVault(t, opts)
+	createFilesA(opts.Wd)
+
+	vault, err := lockgit.Open(filepath.Join(opts.Wd, "foo"), lockgit.Options{KeyStore: opts.KeyStore})
+	if err != nil {
+		t.Fatalf("failed to open vault %s", err)
+	}
+	if vault.Path() != opts.Wd {
+		t.Errorf("vault path is %s instead of %s", vault.Path(), opts.Wd)
+	}
+
+	// paths are relative to the directory the vault was opened from
+	changes, err := vault.Add("fileb", "../filea")
+	if err != nil {
+		t.Fatalf("failed to add files %s", err)
+	}
+	if len(changes) != 2 || changes[0].Action != app.ActionAdded {
+		t.Errorf("unexpected changes from add %v", changes)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "filea"), []byte(data2), 0644)
+	records, err := vault.Status()
+	if err != nil {
+		t.Fatalf("status failed %s", err)
+	}
+	states := []app.FileState{records[0].State, records[1].State}
+	if !reflect.DeepEqual(states, []app.FileState{app.StateUpdated, app.StateUnchanged}) {
+		t.Errorf("unexpected status %v", records)
+	}
+
+	changes, err = vault.Commit()
+	if err != nil || len(changes) != 1 || changes[0].Path != "filea" {
+		t.Errorf("unexpected result from commit %v %v", changes, err)
+	}
+
+	changes, err = vault.CloseFiles()
+	if err != nil || len(changes) != 2 {
+		t.Errorf("unexpected result from close %v %v", changes, err)
+	}
+	changes, err = vault.OpenFiles("../filea")
+	if err != nil || len(changes) != 1 || changes[0].Action != app.ActionOpened {
+		t.Errorf("unexpected result from open %v %v", changes, err)
+	}
+
+	changes, err = vault.Remove("fileb")
+	if err != nil || len(changes) != 1 || changes[0].Action != app.ActionRemoved {
+		t.Errorf("unexpected result from remove %v %v", changes, err)
+	}
+}
+
+func TestLibraryReturnsErrors(t *testing.T) {
+	opts := opts("libraryerrors")
+	cleanDir(opts.Wd)
+
+	_, err := lockgit.Open(opts.Wd, lockgit.Options{KeyStore: opts.KeyStore})
+	if _, ok := err.(*lockgit.VaultNotFoundError); !ok {
+		t.Errorf("expected a VaultNotFoundError, got %v", err)
+	}
+
+	setupVault(t, opts)
+	_, err = lockgit.Open(opts.Wd, lockgit.Options{Profile: "missing", KeyStore: opts.KeyStore})
+	if _, ok := err.(*lockgit.ProfileNotFoundError); !ok {
+		t.Errorf("expected a ProfileNotFoundError, got %v", err)
+	}
+
+	// opening the secrets of an empty vault does nothing
+	vault, err := lockgit.Open(opts.Wd, lockgit.Options{KeyStore: opts.KeyStore})
+	if err != nil {
+		t.Fatalf("failed to open vault %s", err)
+	}
+	changes, err := vault.OpenFiles()
+	if err != nil || len(changes) != 0 {
+		t.Errorf("unexpected result from opening an empty vault %v %v", changes, err)
+	}
+
+	// the vault can still be opened without the key, but secrets cannot be added
+	opts.Force = true
+	_, _ = app.UnsetKey(opts)
+	vault, err = lockgit.Open(opts.Wd, lockgit.Options{KeyStore: opts.KeyStore})
+	if err != nil {
+		t.Fatalf("failed to open vault without the key %s", err)
+	}
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "secret"), []byte(data1), 0644)
+	_, err = vault.Add("secret")
+	if _, ok := err.(*lockgit.KeyLoadError); !ok {
+		t.Errorf("expected a KeyLoadError, got %v", err)
+	}
+}
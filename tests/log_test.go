@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/log"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []log.Level{log.LevelQuiet, log.LevelNormal, log.LevelVerbose, log.LevelDebug} {
+		parsed, err := log.ParseLevel(level.String())
+		if err != nil || parsed != level {
+			t.Errorf("parsing %s returned %s %v", level, parsed, err)
+		}
+	}
+	if _, err := log.ParseLevel("loud"); err == nil {
+		t.Error("expected an unknown level to be an error")
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+
+	expected := map[log.Level]string{
+		log.LevelQuiet:   "",
+		log.LevelNormal:  "info\n",
+		log.LevelVerbose: "info\nverbose\n",
+		log.LevelDebug:   "info\nverbose\n",
+	}
+	for level, out := range expected {
+		log.SetLevel(level)
+		stdout := captureStdout(t, func() {
+			log.Info("info")
+			log.Verbose("verbose")
+			log.Debugf("debug messages go to stderr")
+		})
+		if stdout != out {
+			t.Errorf("at %s, logged %q instead of %q", level, stdout, out)
+		}
+	}
+}
+
+func TestErrorFormat(t *testing.T) {
+	defer log.SetFormat(log.FormatText)
+
+	expected := map[log.Format]string{
+		log.FormatText: "failed\n",
+		log.FormatJson: "{\"error\":\"failed\"}\n",
+		log.FormatYaml: "error: failed\n",
+	}
+	for format, out := range expected {
+		log.SetFormat(format)
+		stderr := capture(t, &os.Stderr, func() { log.LogError(errors.New("failed")) })
+		if stderr != out {
+			t.Errorf("with %s, logged %q instead of %q", format, stderr, out)
+		}
+	}
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	return capture(t, &os.Stdout, f)
+}
+
+// Returns what is written to stdout or stderr while f runs
+func capture(t *testing.T, file **os.File, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *file
+	*file = w
+	f()
+	*file = original
+	_ = w.Close()
+	out, _ := ioutil.ReadAll(r)
+	return string(out)
+}
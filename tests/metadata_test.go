@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestAnnotate(t *testing.T) {
+	opts := opts("annotatetest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+	_, _ = app.AddToVault(opts, []string{"dir1/file*"})
+
+	before, _ := app.LsLong(opts)
+	owner := "ops"
+	_, err := app.Annotate(opts, []string{"dir1/filea1"}, app.Annotation{Owner: &owner, AddTags: []string{"prod", "db"}})
+	if err != nil {
+		t.Fatalf("annotate failed: %s", err)
+	}
+	records, err := app.LsLong(opts)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("expected 2 secrets, got %v: %s", records, err)
+	}
+	if records[0].Owner != "ops" || !reflect.DeepEqual(records[0].Tags, []string{"db", "prod"}) || records[0].Created.IsZero() {
+		t.Errorf("expected filea1 to be annotated, got %v", records[0])
+	}
+
+	// like a change to the contents, the annotated secret gets a new datafile so the manifest changes
+	if records[0].Id == before[0].Id || records[1].Id != before[1].Id {
+		t.Errorf("expected only filea1 to get a new datafile, got %v", records)
+	}
+	if datafiles, _ := filepath.Glob(filepath.Join(opts.Wd, ".lockgit", "data", "*")); len(datafiles) != 2 {
+		t.Errorf("expected the old datafile to be deleted, got %v", datafiles)
+	}
+	changes, _ := app.Annotate(opts, []string{"dir1/filea1"}, app.Annotation{Owner: &owner})
+	if len(changes) != 0 {
+		t.Errorf("expected no change when the metadata is the same, got %v", changes)
+	}
+
+	// the metadata is kept when the secret changes, and does not make it look changed
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
+	_, _ = app.Commit(opts)
+	if _, err := app.CheckStatus(opts); err != nil {
+		t.Errorf("expected the vault to be clean: %s", err)
+	}
+
+	tagged := opts
+	tagged.Tags = []string{"prod"}
+	if ls, _ := app.Ls(tagged); !reflect.DeepEqual(ls, []string{"dir1/filea1"}) {
+		t.Errorf("expected only filea1 to have the prod tag, got %v", ls)
+	}
+	status, _ := app.Status(tagged)
+	if len(status) != 1 || status[0].Owner != "ops" {
+		t.Errorf("expected status to show the owner of filea1, got %v", status)
+	}
+
+	_, _ = app.Annotate(tagged, nil, app.Annotation{RemoveTags: []string{"prod"}})
+	if ls, _ := app.Ls(tagged); len(ls) != 0 {
+		t.Errorf("expected no secrets to have the prod tag, got %v", ls)
+	}
+}
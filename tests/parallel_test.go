@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestParallelJobs(t *testing.T) {
+	opts := opts("paralleltest")
+	opts.Jobs = 8
+	setupVault(t, opts)
+
+	dir := filepath.Join(opts.Wd, "secrets")
+	_ = os.Mkdir(dir, 0755)
+	for i := 0; i < 40; i++ {
+		writeSecret(dir, i, "first")
+	}
+	_, err := app.AddToVault(opts, []string{"secrets/*"})
+	if err != nil {
+		t.Fatalf("failed to add files to vault %s", err)
+	}
+
+	// update some files and add some new ones, which are committed in parallel
+	for _, i := range []int{31, 5, 17} {
+		writeSecret(dir, i, "second")
+	}
+	for _, i := range []int{45, 40} {
+		writeSecret(dir, i, "first")
+	}
+	changes, err := app.Commit(opts)
+	if err != nil {
+		t.Fatalf("failed to commit changes %s", err)
+	}
+	expected := []app.FileChange{
+		{Path: "secrets/05", Action: app.ActionUpdated},
+		{Path: "secrets/17", Action: app.ActionUpdated},
+		{Path: "secrets/31", Action: app.ActionUpdated},
+		{Path: "secrets/40", Action: app.ActionAdded},
+		{Path: "secrets/45", Action: app.ActionAdded},
+	}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("commit returned %v instead of %v", changes, expected)
+	}
+
+	records, _ := app.Status(opts)
+	if len(records) != 42 {
+		t.Fatalf("expected 42 status records, got %d", len(records))
+	}
+	for i, record := range records {
+		if record.State != app.StateUnchanged {
+			t.Errorf("%s should be unchanged but is %s", record.Path, record.State)
+		}
+		if i > 0 && records[i-1].Path >= record.Path {
+			t.Errorf("status records are not sorted: %s is before %s", records[i-1].Path, record.Path)
+		}
+	}
+
+	// errors from closing are reported in the order of the manifest
+	for _, i := range []int{22, 8} {
+		writeSecret(dir, i, "third")
+	}
+	changes, err = app.CloseVault(opts, nil)
+	partial, ok := err.(*app.PartialError)
+	if !ok || len(partial.Errors) != 2 {
+		t.Fatalf("expected 2 errors from closing changed files, got %v", err)
+	}
+	for i, name := range []string{"08", "22"} {
+		if expectedErr := fmt.Sprintf("secrets/%s has changed", name); !strings.HasPrefix(partial.Errors[i].Error(), expectedErr) {
+			t.Errorf("error %d was %q instead of %q", i, partial.Errors[i], expectedErr)
+		}
+	}
+	if len(changes) != 40 || changes[0].Path != "secrets/00" || changes[39].Path != "secrets/45" {
+		t.Errorf("close returned the wrong changes %v", changes)
+	}
+
+	opts.Force = true
+	changes, err = app.OpenVault(opts, nil)
+	if err != nil {
+		t.Fatalf("failed to open vault %s", err)
+	}
+	for i, change := range changes {
+		if change.Action != app.ActionOpened || (i > 0 && changes[i-1].Path >= change.Path) {
+			t.Errorf("open returned the wrong changes %v", changes)
+			break
+		}
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "31"))
+	if string(data) != "second 31" {
+		t.Errorf("secrets/31 was opened with %q", data)
+	}
+}
+
+func writeSecret(dir string, i int, data string) {
+	_ = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d", i)), []byte(fmt.Sprintf("%s %d", data, i)), 0644)
+}
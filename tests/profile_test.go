@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestProfiles(t *testing.T) {
+	opts := opts("profiletest")
+	setupVault(t, opts)
+	for _, name := range []string{"dev", "prod"} {
+		if _, err := app.AddProfile(opts, name); err != nil {
+			t.Fatalf("failed to add profile %s", err)
+		}
+	}
+	if _, err := app.AddProfile(opts, "prod"); err == nil {
+		t.Errorf("expected adding a profile twice to fail")
+	}
+	// the file key store nests settings at dots, so a profile named a.b could not be read back
+	if _, err := app.AddProfile(opts, "a.b"); err == nil {
+		t.Errorf("expected a profile name with a dot to be rejected")
+	}
+
+	dev, prod := opts, opts
+	dev.Profile, prod.Profile = "dev", "prod"
+	files := map[string]app.Options{"shared": opts, "dev.env": dev, "prod.env": prod}
+	for name, fileOpts := range files {
+		_ = ioutil.WriteFile(filepath.Join(opts.Wd, name), []byte(data1), 0644)
+		if _, err := app.AddToVault(fileOpts, []string{name}); err != nil {
+			t.Fatalf("failed to add %s: %s", name, err)
+		}
+	}
+	if ls, _ := app.Ls(prod); !reflect.DeepEqual(ls, []string{"prod.env"}) {
+		t.Errorf("expected only prod.env in the prod profile, got %v", ls)
+	}
+	if ls, _ := app.Ls(opts); !reflect.DeepEqual(ls, []string{"shared"}) {
+		t.Errorf("expected only shared in the default profile, got %v", ls)
+	}
+	expected := []app.ProfileRecord{{Name: "dev", HasKey: true, Files: 1}, {Name: "prod", HasKey: true, Files: 1}}
+	if profiles, _ := app.ListProfiles(opts); !reflect.DeepEqual(profiles, expected) {
+		t.Errorf("expected profiles %v, got %v", expected, profiles)
+	}
+
+	// someone with only the dev key can still use the dev profile
+	prod.Force = true
+	_, _ = app.UnsetKey(prod)
+	_ = os.Remove(filepath.Join(opts.Wd, "dev.env"))
+	_ = os.Remove(filepath.Join(opts.Wd, "prod.env"))
+	changes, err := app.OpenVault(dev, nil)
+	if err != nil || len(changes) != 1 || changes[0].Path != "dev.env" {
+		t.Errorf("expected open to only restore dev.env, got %v: %s", changes, err)
+	}
+	if _, err := app.CheckStatus(dev); err != nil {
+		t.Errorf("expected the dev profile to be clean: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.Wd, "prod.env")); !os.IsNotExist(err) {
+		t.Errorf("expected prod.env to stay closed")
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/cmd"
+	"github.com/jswidler/lockgit/pkg/log"
+)
+
+func TestRender(t *testing.T) {
+	opts := opts("rendertest")
+	setupVault(t, opts)
+	_ = os.Mkdir(filepath.Join(opts.Wd, "config"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "config", "creds.json"), []byte(`{"db": {"password": "hunter2", "ports": [5432]}}`), 0644)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "config", ".env"), []byte("# tokens\nexport API_TOKEN=\"abc123\"\n"), 0644)
+	_, err := app.AddToVault(opts, []string{"config"})
+	if err != nil {
+		t.Fatalf("failed to add files %s", err)
+	}
+
+	tmpl := filepath.Join(opts.Wd, "app.yml.tmpl")
+	_ = ioutil.WriteFile(tmpl, []byte(`password: {{ json "config/creds.json" "db.password" }}
+port: {{ json "config/creds.json" "db.ports.0" }}
+token: {{ kv "API_TOKEN" }}
+`), 0644)
+	expected := "password: hunter2\nport: 5432\ntoken: abc123\n"
+	data, err := app.Render(opts, "app.yml.tmpl")
+	if err != nil || string(data) != expected {
+		t.Errorf("expected rendered template %q, got %q: %s", expected, data, err)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "bad.tmpl"), []byte(`{{ secret "config/missing" }}`), 0644)
+	_, err = app.Render(opts, "bad.tmpl")
+	if err == nil {
+		t.Errorf("expected a secret which is not in the vault to fail")
+	}
+
+	out := filepath.Join(opts.Wd, "app.yml")
+	_, err = app.RenderToFile(opts, "app.yml.tmpl", "app.yml", true)
+	if err != nil {
+		t.Fatalf("failed to render file %s", err)
+	}
+	info, err := os.Stat(out)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected rendered file to only be readable by the owner")
+	}
+
+	changes, err := app.CloseVault(opts, nil)
+	if err != nil || len(changes) != 3 {
+		t.Errorf("expected close to delete 2 secrets and the rendered file, got %v: %s", changes, err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected close to delete the rendered file")
+	}
+}
+
+func TestRenderCommandOutFile(t *testing.T) {
+	opts := opts("rendercmdtest")
+	setupVault(t, opts)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "creds"), []byte("hunter2"), 0644)
+	_, _ = app.AddToVault(opts, []string{"creds"})
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "t.tmpl"), []byte(`password: {{ secret "creds" }}`), 0644)
+
+	testWd, _ := os.Getwd()
+	_ = os.Chdir(opts.Wd)
+	t.Cleanup(func() {
+		_ = os.Chdir(testWd)
+		log.SetFormat(log.FormatText)
+	})
+
+	// -o is the output format of the command, not the file to write
+	root := cmd.Command()
+	root.SetArgs([]string{"render", "t.tmpl", "--out-file", "app.yml", "-o", "json", "--config", "config.yml"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("render failed %s", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(opts.Wd, "app.yml"))
+	if err != nil || string(data) != "password: hunter2" {
+		t.Errorf("expected the template to be rendered to app.yml, got %q: %s", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.Wd, "json")); !os.IsNotExist(err) {
+		t.Errorf("expected -o json not to write a file named json")
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
+)
+
+func TestFileChanges(t *testing.T) {
+	opts := opts("filechangestest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+
+	changes, err := app.AddToVault(opts, []string{"dir1/file*"})
+	if err != nil {
+		t.Fatalf("failed to add files %s", err)
+	}
+	expected := []app.FileChange{
+		{Path: "dir1/file*", Action: app.ActionPatternAdded},
+		{Path: "dir1/filea1", Action: app.ActionAdded},
+		{Path: "dir1/fileb1", Action: app.ActionAdded},
+	}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Fatalf("add returned %v instead of %v", changes, expected)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
+	changes, err = app.Commit(opts)
+	if err != nil {
+		t.Fatalf("failed to commit %s", err)
+	}
+	expected = []app.FileChange{{Path: "dir1/filea1", Action: app.ActionUpdated}}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Fatalf("commit returned %v instead of %v", changes, expected)
+	}
+
+	changes, err = app.CloseVault(opts, []string{"dir1/filea1"})
+	if err != nil {
+		t.Fatalf("failed to close %s", err)
+	}
+	expected = []app.FileChange{{Path: "dir1/filea1", Action: app.ActionClosed}}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Fatalf("close returned %v instead of %v", changes, expected)
+	}
+
+	changes, err = app.OpenVault(opts, nil)
+	if err != nil {
+		t.Fatalf("failed to open %s", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected open to return 2 changes, got %v", changes)
+	}
+	if changes[0].Action != app.ActionOpened || changes[1].Action != app.ActionSkipped || changes[1].Reason == "" {
+		t.Errorf("expected filea1 to be opened and fileb1 to be skipped, got %v", changes)
+	}
+
+	changes, _ = app.RemoveFromVault(opts, []string{"dir1/file*"})
+	if len(changes) != 3 || changes[0].Action != app.ActionPatternRemoved {
+		t.Errorf("expected rm to remove the pattern and both files, got %v", changes)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	opts := opts("exitcodetest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+	_, _ = app.AddToVault(opts, []string{"dir1/file*"})
+
+	changes, err := app.OpenVault(opts, []string{"dir1/filea1", "nosuchfile"})
+	if len(changes) != 1 {
+		t.Errorf("expected filea1 to be opened, got %v", changes)
+	}
+	if _, ok := err.(*app.PartialError); !ok || log.ExitCodeOf(err) != log.ExitPartial {
+		t.Errorf("expected a partial error, got %v", err)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
+	_, err = app.CloseVault(opts, nil)
+	if log.ExitCodeOf(err) != log.ExitPartial {
+		t.Errorf("expected closing a changed file to be a partial failure, got %v", err)
+	}
+
+	_, err = app.AddToVault(opts, []string{"nosuchfile"})
+	if log.ExitCodeOf(err) != log.ExitPartial {
+		t.Errorf("expected adding a missing file to be a partial failure, got %v", err)
+	}
+
+	if log.ExitCodeOf(nil) != log.ExitOk {
+		t.Errorf("expected no error to exit with %d", log.ExitOk)
+	}
+}
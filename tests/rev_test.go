@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestCatAndOpenRevision(t *testing.T) {
+	opts := opts("revtest")
+	setupVault(t, opts)
+	setupGitRepo(t, opts.Wd)
+
+	files := createFilesA(opts.Wd)
+	_, _ = app.AddToVault(opts, files)
+	gitCommit(t, opts.Wd, "add files")
+
+	_ = ioutil.WriteFile(files[0], []byte(data2), 0644)
+	_, _ = app.Commit(opts)
+	gitCommit(t, opts.Wd, "change filea")
+
+	data, err := app.Cat(opts, "filea")
+	if err != nil || string(data) != data2 {
+		t.Errorf("expected cat to return the current version: %s", err)
+	}
+
+	revOpts := opts
+	revOpts.Rev = "HEAD~1"
+	data, err = app.Cat(revOpts, "filea")
+	if err != nil || string(data) != data1 {
+		t.Errorf("expected cat to return the previous version: %s", err)
+	}
+
+	_, err = app.Cat(revOpts, "nosuchfile")
+	if err == nil {
+		t.Error("expected cat to fail for a file not in the vault")
+	}
+
+	app.CloseVault(opts, nil)
+	app.OpenVault(revOpts, []string{"filea"})
+
+	data, _ = ioutil.ReadFile(files[0])
+	if string(data) != data1 {
+		t.Errorf("expected open to restore the previous version")
+	}
+	if _, err := os.Stat(files[1]); !os.IsNotExist(err) {
+		t.Errorf("expected open to only restore the requested file")
+	}
+
+	// The working directory should not have been changed by reading the revision
+	manifest, _ := ioutil.ReadFile(filepath.Join(opts.Wd, ".lockgit", "manifest"))
+	status := gitRun(t, opts.Wd, "status", "--porcelain", ".lockgit")
+	if status != "" || len(manifest) == 0 {
+		t.Errorf("expected the vault to be unchanged, got %s", status)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/jswidler/lockgit/pkg/app"
+	"github.com/jswidler/lockgit/pkg/log"
 )
 
 func TestStatus(t *testing.T) {
@@ -13,60 +14,82 @@ func TestStatus(t *testing.T) {
 	setupVault(t, opts)
 	createFilesC(opts.Wd)
 
-	app.AddToVault(opts, []string{"dir1/*"})
-	addedFiles := app.Ls(opts)
+	app.AddToVault(opts, []string{"dir1/file*"})
+	addedFiles, _ := app.Ls(opts)
 	if len(addedFiles) != 2 {
 		t.Errorf("expected 2 files in the vault")
 	}
 
-	_, table := app.Status(opts)
-	if len(table) != 2 {
+	records, _ := app.Status(opts)
+	if len(records) != 2 {
 		t.Errorf("expected 2 files in the vault")
 	}
 
 	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
 	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea15"), []byte(data2), 0644)
 
-	headers, table := app.Status(opts)
-	if headers[1] != "updated" {
-		t.Errorf("expected second column to be the updated column")
-	}
-	if table[0][0] != "dir1/filea1" {
+	records, _ = app.Status(opts)
+	if records[0].Path != "dir1/filea1" {
 		t.Errorf("expected first file to be filea1")
 	}
-	if table[0][1] != "true" {
+	if records[0].State != app.StateUpdated || records[0].Updated() != "true" {
 		t.Errorf("expected first file to be updated")
 	}
-	if table[1][0] != "dir1/filea15" {
+	if records[1].Path != "dir1/filea15" {
 		t.Errorf("expected second file to be filea15")
 	}
-	if table[1][1] != "new file" {
+	if records[1].State != app.StateNew || records[1].Updated() != "new file" {
 		t.Errorf("expected second file to be new")
 	}
-	if table[2][0] != "dir1/fileb1" {
+	if records[2].Path != "dir1/fileb1" {
 		t.Errorf("expected third file to be fileb1")
 	}
-	if table[2][1] != "false" {
+	if records[2].State != app.StateUnchanged || records[2].Updated() != "false" {
 		t.Errorf("expected first file to the same")
 	}
 
-	if table[0][3] == "" {
+	if records[0].Id == "" {
 		t.Errorf("expected first file to have an id")
 	}
-	if table[1][3] != "" {
+	if records[1].Id != "" {
 		t.Errorf("expected second file not to have an id")
 	}
+	if records[0].Perm != "0644" || records[1].Perm != "0644" {
+		t.Errorf("expected files to have permissions 0644, got %s and %s", records[0].Perm, records[1].Perm)
+	}
 
 	app.AddToVault(opts, []string{"dir2"})
-	_, table = app.Status(opts)
-	if len(table) != 9 {
+	records, _ = app.Status(opts)
+	if len(records) != 9 {
 		t.Errorf("expected 9 files in the vault")
 	}
 
-	if table[0][2] != "dir1/*" {
-		t.Errorf("expected first file to have pattern dir1/*")
+	if records[0].Pattern != "dir1/file*" {
+		t.Errorf("expected first file to have pattern dir1/file*")
 	}
-	if table[3][2] != "dir2/**" {
+	if records[3].Pattern != "dir2/**" {
 		t.Errorf("expected fourth file not to have pattern dir2/**")
 	}
 }
+
+func TestCheckStatus(t *testing.T) {
+	opts := opts("checkstatustest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+
+	_, _ = app.AddToVault(opts, []string{"dir1/file*"})
+	dirty, err := app.CheckStatus(opts)
+	if err != nil || len(dirty) != 0 {
+		t.Fatalf("expected the vault to be clean, got %v: %s", dirty, err)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea15"), []byte(data2), 0644)
+	dirty, err = app.CheckStatus(opts)
+	if len(dirty) != 2 || dirty[0].Path != "dir1/filea1" || dirty[1].Path != "dir1/filea15" {
+		t.Errorf("expected filea1 and filea15 to be dirty, got %v", dirty)
+	}
+	if log.ExitCodeOf(err) != log.ExitDirty {
+		t.Errorf("expected a dirty vault to exit with %d, got %v", log.ExitDirty, err)
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestVaultRegistry(t *testing.T) {
+	opts := opts("vaultregistrytest")
+	setupVault(t, opts)
+
+	other := opts
+	other.Wd = filepath.Join(opts.Wd, "other")
+	_ = os.MkdirAll(other.Wd, 0755)
+	if _, err := app.InitVault(other); err != nil {
+		t.Fatalf("failed to init the second vault %s", err)
+	}
+
+	vaults, _ := app.ListVaults(opts)
+	if len(vaults) != 2 {
+		t.Fatalf("expected 2 vaults, got %v", vaults)
+	}
+	if vaults[0].Path != opts.Wd || !vaults[0].HasKey || !vaults[0].Exists {
+		t.Errorf("expected the first vault to exist with a key, got %v", vaults[0])
+	}
+
+	_ = os.RemoveAll(other.Wd)
+	pruned, err := app.PruneVaults(opts, false)
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("expected a vault with a key not to be pruned, got %v: %s", pruned, err)
+	}
+	_, err = app.PruneVaults(opts, true)
+	if err == nil {
+		t.Errorf("expected pruning keys to require --force")
+	}
+
+	forced := opts
+	forced.Force = true
+	pruned, err = app.PruneVaults(forced, true)
+	if err != nil || len(pruned) != 1 || pruned[0].Path != other.Wd {
+		t.Errorf("expected the removed vault to be pruned, got %v: %s", pruned, err)
+	}
+	if vaults, _ = app.ListVaults(opts); len(vaults) != 1 {
+		t.Errorf("expected 1 vault after pruning, got %v", vaults)
+	}
+
+	if _, err = app.ForgetVault(opts, vaults[0].Id); err == nil {
+		t.Errorf("expected forgetting a vault with a key to require --force")
+	}
+	if _, err = app.ForgetVault(forced, vaults[0].Id); err != nil {
+		t.Errorf("failed to forget vault %s", err)
+	}
+	if vaults, _ = app.ListVaults(opts); len(vaults) != 0 {
+		t.Errorf("expected no vaults after forgetting, got %v", vaults)
+	}
+}
+
+func TestRelocateVault(t *testing.T) {
+	opts := opts("relocatevaulttest")
+	setupVault(t, opts)
+
+	other := opts
+	other.Wd = filepath.Join(opts.Wd, "other")
+	_ = os.MkdirAll(other.Wd, 0755)
+	_, _ = app.InitVault(other)
+	vaults, _ := app.ListVaults(opts)
+	if len(vaults) != 2 || vaults[1].Path != other.Wd {
+		t.Fatalf("expected 2 vaults, got %v", vaults)
+	}
+	id := vaults[1].Id
+
+	moved := filepath.Join(opts.Wd, "moved")
+	_ = os.Rename(other.Wd, moved)
+	if _, err := app.RelocateVault(opts, vaults[0].Id, moved); err == nil {
+		t.Errorf("expected relocating a vault to a vault with another id to fail")
+	}
+	if _, err := app.RelocateVault(opts, id, filepath.Join(opts.Wd, "missing")); err == nil {
+		t.Errorf("expected relocating a vault to a directory without a vault to fail")
+	}
+	record, err := app.RelocateVault(opts, id, "moved")
+	if err != nil || record.Path != moved || !record.Exists {
+		t.Fatalf("expected the vault to be relocated, got %v: %s", record, err)
+	}
+	if vaults, _ = app.ListVaults(opts); len(vaults) != 2 || vaults[1].Path != moved || !vaults[1].Exists {
+		t.Errorf("expected the relocated vault to be listed at its new path, got %v", vaults)
+	}
+
+	// a different vault at the saved path does not keep the old vault from being pruned
+	_ = os.RemoveAll(moved)
+	_ = os.MkdirAll(moved, 0755)
+	other.Wd = moved
+	_, _ = app.InitVault(other)
+	forced := opts
+	forced.Force = true
+	pruned, err := app.PruneVaults(forced, true)
+	if err != nil || len(pruned) != 1 || pruned[0].Id != id {
+		t.Errorf("expected the vault replaced by another to be pruned, got %v: %s", pruned, err)
+	}
+}
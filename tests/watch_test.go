@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jswidler/lockgit/pkg/app"
+)
+
+func TestWatch(t *testing.T) {
+	opts := opts("watchtest")
+	setupVault(t, opts)
+	createFilesC(opts.Wd)
+	_, _ = app.AddToVault(opts, []string{"dir1/**"})
+
+	stop := make(chan struct{})
+	commits := make(chan []app.FileChange, 8)
+	ready := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- app.Watch(opts, 50*time.Millisecond, stop, ready, func(changes []app.FileChange, err error) {
+			if err != nil {
+				t.Errorf("commit failed: %s", err)
+			}
+			commits <- changes
+		})
+	}()
+	<-ready
+
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data2), 0644)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "filea1"), []byte(data1+data2), 0644)
+	changes := waitForCommit(t, commits)
+	if len(changes) != 1 || changes[0].Path != "dir1/filea1" || changes[0].Action != app.ActionUpdated {
+		t.Errorf("expected one commit updating filea1, got %v", changes)
+	}
+
+	_ = os.Mkdir(filepath.Join(opts.Wd, "dir1", "newdir"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir1", "newdir", "newfile"), []byte(data1), 0644)
+	changes = waitForCommit(t, commits)
+	if len(changes) != 1 || changes[0].Path != "dir1/newdir/newfile" || changes[0].Action != app.ActionAdded {
+		t.Errorf("expected newfile to be added, got %v", changes)
+	}
+
+	// a directory which is deleted and recreated is watched again
+	newdir := filepath.Join(opts.Wd, "dir1", "newdir")
+	_ = os.RemoveAll(newdir)
+	waitForCommit(t, commits)
+	_ = os.Mkdir(newdir, 0755)
+	waitForCommit(t, commits)
+	_ = ioutil.WriteFile(filepath.Join(newdir, "newfile"), []byte(data2), 0644)
+	changes = waitForCommit(t, commits)
+	if len(changes) != 1 || changes[0].Path != "dir1/newdir/newfile" {
+		t.Errorf("expected newfile to be committed in the recreated directory, got %v", changes)
+	}
+
+	// changes outside of the patterns do not cause a commit
+	_ = ioutil.WriteFile(filepath.Join(opts.Wd, "dir2", "filea2"), []byte(data2), 0644)
+	select {
+	case changes = <-commits:
+		t.Errorf("expected no commit, got %v", changes)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// a pattern added by another command while watching is picked up
+	_, _ = app.AddToVault(opts, []string{"dir2/*"})
+	newfile := filepath.Join(opts.Wd, "dir2", "newfile")
+	changes = nil
+	for i := 0; i < 50 && changes == nil; i++ {
+		// the pattern is only used once the watcher has seen the config change
+		_ = ioutil.WriteFile(newfile, []byte(data1), 0644)
+		select {
+		case changes = <-commits:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if len(changes) != 1 || changes[0].Path != "dir2/newfile" || changes[0].Action != app.ActionAdded {
+		t.Errorf("expected newfile to be added with the new pattern, got %v", changes)
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("watch failed: %s", err)
+	}
+}
+
+func waitForCommit(t *testing.T, commits chan []app.FileChange) []app.FileChange {
+	select {
+	case changes := <-commits:
+		return changes
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a commit")
+		return nil
+	}
+}